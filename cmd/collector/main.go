@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"sync"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ajbosco/statboard/pkg/collector"
 	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
 	"github.com/ajbosco/statboard/pkg/storage"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
@@ -20,6 +27,12 @@ type EnvConfig struct {
 }
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [run [-full-refresh]|daemon|reaggregate|backfill -metric source.metric -from date [-to date] [-file export.csv]|migrate -from storm.db|delete -metric source.metric [-from date] [-to date]|dedupe|export [-format jsonl|csv] [-file path]|import [-format jsonl|csv] [-file path]|snapshot|compact]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	var envCfg EnvConfig
 	err := envconfig.Process("statboard", &envCfg)
 	if err != nil {
@@ -32,76 +45,334 @@ func main() {
 		logrus.Fatal(err)
 	}
 
+	var cfg config.Config
+
+	err = viper.Unmarshal(&cfg)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	// Create metric store
-	s, err := storage.NewStormStore(envCfg.DbFilePath)
+	s, err := storage.Open(cfg.Store.Backend, envCfg.DbFilePath, cfg.Store.DSN)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	var cfg config.Config
+	// Validate configured metrics against registered sources
+	err = collector.Validate(cfg.Metrics)
+	if err != nil {
+		logrus.Fatal(err)
+	}
 
-	err = viper.Unmarshal(&cfg)
+	// Protect stored history from values collected over an incomplete window
+	policy, err := storage.ParseWritePolicy(cfg.Store.WritePolicy)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	var collectWg sync.WaitGroup
-	collectWg.Add(len(cfg.Metrics))
-
-	// Create collectors
-	for metType, metCfgs := range cfg.Metrics {
-		go func(metType string, metCfgs map[string]config.MetricConfig) {
-			defer collectWg.Done()
-			var metWg sync.WaitGroup
-			metWg.Add(len(metCfgs))
-
-			c, err := createCollector(metType, cfg)
-			if err != nil {
-				logrus.Fatal(err)
-			}
-			logrus.Info(fmt.Sprintf("collecting metrics for %q", metType))
-
-			// Collect and write metrics
-			for metName, metCfg := range metCfgs {
-				go func(metName string, metCfg config.MetricConfig) {
-					defer metWg.Done()
-					metricName := fmt.Sprintf("%s.%s", metType, metName)
-					logrus.Info(fmt.Sprintf("collecting %q", metricName))
-					metrics, err := c.Collect(metName, metCfg.CollectMonthsBack)
-					if err != nil {
-						logrus.Fatal(errors.Wrap(err, fmt.Sprintf("failed to collect metric:%q", metricName)))
-					}
-					logrus.Info(fmt.Sprintf("collected %d %q records", len(metrics), metricName))
-
-					for _, met := range metrics {
-						err = s.WriteMetric(met)
-						if err != nil {
-							logrus.Fatal(errors.Wrap(err, fmt.Sprintf("failed to write metric:%q", metricName)))
-						}
-					}
-					logrus.Info(fmt.Sprintf("wrote %d %q records to database", len(metrics), metricName))
-				}(metName, metCfg)
-			}
-			metWg.Wait()
-			logrus.Info(fmt.Sprintf("completed collecting metrics for %q", metType))
-		}(metType, metCfgs)
-	}
-	collectWg.Wait()
+	runner := collector.NewRunner(cfg, storage.WithWritePolicy(s, policy))
+	// Rebuilding from raw events, backfills and compaction write the values
+	// they compute from stored history, so they bypass the write policy
+	rebuilder := collector.NewRunner(cfg, s)
+
+	// Apply retention and back up the database after collections
+	afterRun := func() {
+		if err := rebuilder.Compact(time.Now()).Err(); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to compact metrics"))
+		}
+		if cfg.Store.SnapshotDir == "" {
+			return
+		}
+		if _, err := storage.Snapshot(s, cfg.Store.SnapshotDir, cfg.Store.SnapshotsToKeep()); err != nil {
+			logrus.Error(errors.Wrap(err, "failed to snapshot database"))
+		}
+	}
+
+	cmd := flag.Arg(0)
+	switch cmd {
+	case "", "run":
+		runFlags := flag.NewFlagSet("run", flag.ExitOnError)
+		fullRefresh := runFlags.Bool("full-refresh", false, "ignore checkpoints and collect the full collect_months_back range")
+		runFlags.Parse(commandArgs())
+		runner.SetFullRefresh(*fullRefresh)
+		err = runOnce(runner, afterRun)
+	case "daemon":
+		err = runDaemon(runner, afterRun)
+	case "reaggregate":
+		summary := rebuilder.Reaggregate()
+		summary.Log()
+		err = summary.Err()
+	case "backfill":
+		err = runBackfill(rebuilder, cfg)
+	case "migrate":
+		err = runMigrate(cfg, s)
+	case "delete":
+		err = runDelete(s)
+	case "dedupe":
+		_, err = storage.DedupeMetrics(s)
+	case "export":
+		err = runExport(s)
+	case "import":
+		err = runImport(s)
+	case "snapshot":
+		err = runSnapshot(cfg, s)
+	case "compact":
+		err = rebuilder.Compact(time.Now()).Err()
+	default:
+		err = fmt.Errorf("unknown command %q, valid commands are: run, daemon, reaggregate, backfill, migrate, delete, dedupe, export, import, snapshot, compact", cmd)
+	}
+
+	if closeErr := s.Close(); closeErr != nil {
+		logrus.Error(errors.Wrap(closeErr, "failed to close database"))
+	}
+	if err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+}
+
+// runOnce collects every configured metric a single time, then calls afterRun
+func runOnce(runner *collector.Runner, afterRun func()) error {
+	// Cancel in-flight collections on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := notifyShutdown()
+	go func() {
+		<-sigs
+		logrus.Info("received shutdown signal, cancelling collection")
+		cancel()
+	}()
+
+	summary := runner.Run(ctx)
+	summary.Log()
+
+	afterRun()
+	return summary.Err()
+}
+
+// runDaemon collects metrics on their configured schedules until it is
+// signalled to stop. Running collections are allowed to finish unless a
+// second signal is received.
+func runDaemon(runner *collector.Runner, afterRun func()) error {
+	sched, err := collector.NewScheduler(runner)
+	if err != nil {
+		return err
+	}
+	sched.SetAfterRun(afterRun)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := notifyShutdown()
+
+	sched.Start(ctx)
+	logrus.Info("collector daemon started")
+
+	<-sigs
+	logrus.Info("received shutdown signal, waiting for running collections to finish")
+	go func() {
+		<-sigs
+		logrus.Info("received second shutdown signal, cancelling running collections")
+		cancel()
+	}()
+	sched.Stop()
+	logrus.Info("collector daemon stopped")
+
+	return nil
 }
 
-func createCollector(collectorType string, cfg config.Config) (collector.Collector, error) {
-	var c collector.Collector
+// runBackfill writes the history of a metric from before the window of its
+// regular API, either from the source's backfill API or from a data export
+func runBackfill(runner *collector.Runner, cfg config.Config) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	metric := fs.String("metric", "", "metric to backfill as source.metric")
+	from := fs.String("from", "", "first day to backfill as 2006-01-02")
+	to := fs.String("to", time.Now().AddDate(0, 0, -1).Format("2006-01-02"), "last day to backfill as 2006-01-02")
+	file := fs.String("file", "", "csv data export with date,value rows to backfill from instead of the source's API")
+	fs.Parse(commandArgs())
+
+	parts := strings.SplitN(*metric, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("-metric must be given as source.metric, got %q", *metric)
+	}
+	sourceName, metName := parts[0], parts[1]
+	if _, ok := cfg.Metrics[sourceName][metName]; !ok {
+		return fmt.Errorf("metric %q is not configured", *metric)
+	}
+
+	var opts collector.Options
 	var err error
+	if opts.Start, err = time.Parse("2006-01-02", *from); err != nil {
+		return errors.Wrap(err, "-from must be a date as 2006-01-02")
+	}
+	if opts.End, err = time.Parse("2006-01-02", *to); err != nil {
+		return errors.Wrap(err, "-to must be a date as 2006-01-02")
+	}
 
-	switch collectorType {
-	case "fitbit":
-		c, err = collector.NewFitbitCollector(cfg)
-	case "github":
-		c, err = collector.NewGithubCollector(cfg)
-	case "goodreads":
-		c, err = collector.NewGoodreadsCollector(cfg)
-	default:
-		err = fmt.Errorf("Unsupported collector type:%q", collectorType)
+	var res collector.MetricResult
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to open %q", *file))
+		}
+		defer f.Close()
+
+		events, err := collector.ReadBackfillCSV(sourceName, f)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to read %q", *file))
+		}
+		res = runner.BackfillEvents(sourceName, metName, events, opts)
+	} else {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigs := notifyShutdown()
+		go func() {
+			<-sigs
+			logrus.Info("received shutdown signal, cancelling backfill")
+			cancel()
+		}()
+		res = runner.Backfill(ctx, sourceName, metName, opts)
 	}
-	return c, err
+
+	summary := &collector.RunSummary{Results: []collector.MetricResult{res}}
+	summary.Log()
+	return summary.Err()
+}
+
+// runMigrate copies the contents of a Storm file into the configured store
+func runMigrate(cfg config.Config, s storage.Store) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "storm database file to migrate from")
+	fs.Parse(commandArgs())
+
+	if *from == "" {
+		return errors.New("-from must be set to the storm database file to migrate from")
+	}
+	if b := storage.Backend(cfg.Store.Backend); b == "" || b == storage.Storm {
+		return errors.New("'store.backend' must be set to the store to migrate to")
+	}
+	return storage.MigrateStorm(*from, s)
+}
+
+// runDelete deletes the stored values of a metric, for example after it was
+// collected with a bad config
+func runDelete(s storage.Store) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	metric := fs.String("metric", "", "metric to delete as source.metric")
+	from := fs.String("from", "", "first day to delete as 2006-01-02, all history if unset")
+	to := fs.String("to", "", "day after the last day to delete as 2006-01-02, no end if unset")
+	fs.Parse(commandArgs())
+
+	if *metric == "" {
+		return errors.New("-metric must be set to the metric to delete")
+	}
+
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = time.Parse("2006-01-02", *from); err != nil {
+			return errors.Wrap(err, "-from must be a date as 2006-01-02")
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse("2006-01-02", *to); err != nil {
+			return errors.Wrap(err, "-to must be a date as 2006-01-02")
+		}
+	}
+
+	n, err := s.DeleteMetric(*metric, start, end)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to delete %q", *metric))
+	}
+	logrus.Info(fmt.Sprintf("deleted %d %q records", n, *metric))
+
+	// Reset the checkpoint so the next run collects the deleted range again
+	if err := s.WriteCheckpoint(statboard.Checkpoint{Metric: *metric, Updated: time.Now()}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to reset %q checkpoint", *metric))
+	}
+	return nil
+}
+
+// runExport writes every stored metric, and with jsonl its history, to a file or stdout
+func runExport(s storage.Store) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", string(storage.JSONLines), "export format: jsonl or csv")
+	file := fs.String("file", "", "file to export to, stdout if unset")
+	fs.Parse(commandArgs())
+
+	f, err := storage.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *file != "" {
+		if w, err = os.Create(*file); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to create %q", *file))
+		}
+	}
+
+	n, err := storage.Export(s, w, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to export metrics")
+	}
+	if w != os.Stdout {
+		if err := w.Close(); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to write %q", *file))
+		}
+	}
+	logrus.Info(fmt.Sprintf("exported %d records", n))
+	return nil
+}
+
+// runImport loads the records written by export from a file or stdin. Imported
+// values replace stored ones regardless of the write policy.
+func runImport(s storage.Store) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", string(storage.JSONLines), "import format: jsonl or csv")
+	file := fs.String("file", "", "file to import from, stdin if unset")
+	fs.Parse(commandArgs())
+
+	f, err := storage.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	r := os.Stdin
+	if *file != "" {
+		if r, err = os.Open(*file); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to open %q", *file))
+		}
+		defer r.Close()
+	}
+
+	n, err := storage.Import(s, r, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to import metrics")
+	}
+	logrus.Info(fmt.Sprintf("imported %d records", n))
+	return nil
+}
+
+// runSnapshot writes a database snapshot to the configured snapshot dir
+func runSnapshot(cfg config.Config, s storage.Store) error {
+	if cfg.Store.SnapshotDir == "" {
+		return errors.New("'store.snapshot_dir' must be set to take snapshots")
+	}
+	_, err := storage.Snapshot(s, cfg.Store.SnapshotDir, cfg.Store.SnapshotsToKeep())
+	return err
+}
+
+// commandArgs returns the arguments following the command
+func commandArgs() []string {
+	if flag.NArg() < 2 {
+		return nil
+	}
+	return flag.Args()[1:]
+}
+
+// notifyShutdown returns a channel that receives interrupt and termination signals
+func notifyShutdown() chan os.Signal {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	return sigs
 }
@@ -28,15 +28,21 @@ func main() {
 		logrus.Fatal(err)
 	}
 
-	// Create metric store
-	s, err := storage.NewStormStore(envCfg.DbFilePath)
+	var cfg config.Config
+
+	err = viper.Unmarshal(&cfg)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	var cfg config.Config
+	// Validate chart types and options before serving the dashboard
+	err = reporter.ValidateCharts(cfg)
+	if err != nil {
+		logrus.Fatal(err)
+	}
 
-	err = viper.Unmarshal(&cfg)
+	// Create metric store
+	s, err := storage.Open(cfg.Store.Backend, envCfg.DbFilePath, cfg.Store.DSN)
 	if err != nil {
 		logrus.Fatal(err)
 	}
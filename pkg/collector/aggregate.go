@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/ajbosco/statboard/pkg/storage"
+	"github.com/pkg/errors"
+)
+
+// Aggregate builds the metrics of every period from start to end out of the
+// raw events stored for the source
+func Aggregate(store storage.Store, sourceName string, metName string, g statboard.Granularity, start time.Time, end time.Time) ([]statboard.Metric, error) {
+	agg, err := aggregatorFor(sourceName, metName)
+	if err != nil {
+		return nil, err
+	}
+
+	// create metric for each period in range
+	metrics := generateEmptyMetrics(metricName(sourceName, metName), g, start, end)
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	// events are compared by calendar date, so include a day on either side
+	// of the range for timestamps with a UTC offset
+	from := metrics[0].Date.AddDate(0, 0, -1)
+	to := g.Next(metrics[len(metrics)-1].Date).AddDate(0, 0, 1)
+	events, err := store.GetEvents(sourceName, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to get %q events", sourceName))
+	}
+
+	metrics, err = agg(events, metrics)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate events")
+	}
+	return metrics, nil
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Backfiller is implemented by collectors that can read history from beyond
+// the window of the API they are regularly collected from. Backfilled events
+// carry the total of a day in their Value.
+type Backfiller interface {
+	Backfill(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error)
+}
+
+// Backfill writes the older periods of a metric from the source's Backfiller
+func (r *Runner) Backfill(ctx context.Context, sourceName string, metName string, opts Options) MetricResult {
+	res := MetricResult{Metric: metricName(sourceName, metName)}
+
+	c, err := r.newCollector(sourceName, r.cfg)
+	if err != nil {
+		res.Status = StatusSkipped
+		res.Err = err
+		return res
+	}
+	b, ok := c.(Backfiller)
+	if !ok {
+		res.Status = StatusSkipped
+		res.Err = fmt.Errorf("source %q cannot backfill, import a data export instead", sourceName)
+		return res
+	}
+
+	logrus.Info(fmt.Sprintf("backfilling %q from %s to %s", res.Metric, opts.Start.Format("2006-01-02"), opts.End.Format("2006-01-02")))
+	events, err := b.Backfill(ctx, metName, opts)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to backfill metric")
+		return res
+	}
+
+	return r.BackfillEvents(sourceName, metName, events, opts)
+}
+
+// BackfillEvents writes the periods of a metric that are fully covered by the
+// range in opts, summing the values of the given events. A period that adds up
+// to zero never replaces a non-zero value already in the store, since the
+// history it was built from may be incomplete.
+func (r *Runner) BackfillEvents(sourceName string, metName string, events []statboard.Event, opts Options) MetricResult {
+	name := metricName(sourceName, metName)
+	res := MetricResult{Metric: name}
+
+	g := r.cfg.Metrics[sourceName][metName].Granularity.OrDefault()
+	start, end, ok := completePeriods(g, opts.Start, opts.End)
+	if !ok {
+		res.Status = StatusSkipped
+		res.Err = fmt.Errorf("no complete %s between %s and %s", g, opts.Start.Format("2006-01-02"), opts.End.Format("2006-01-02"))
+		return res
+	}
+
+	existing, err := r.store.GetMetric(name, g, start.AddDate(0, 0, -1))
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to get stored metrics")
+		return res
+	}
+	stored := make(map[string]float64)
+	for _, met := range existing {
+		stored[met.Date.UTC().Format("2006-01-02")] = met.Value
+	}
+
+	var metrics []statboard.Metric
+	for _, met := range sumEvents(events, generateEmptyMetrics(name, g, start, end)) {
+		date := met.Date.Format("2006-01-02")
+		if met.Value == 0 && stored[date] != 0 {
+			logrus.Info(fmt.Sprintf("keeping stored %q value %v for %s", name, stored[date], date))
+			continue
+		}
+		metrics = append(metrics, met)
+	}
+
+	return r.writeMetrics(res, metrics)
+}
+
+// completePeriods returns the first and last period that lie entirely within
+// the days from start to end
+func completePeriods(g statboard.Granularity, start time.Time, end time.Time) (time.Time, time.Time, bool) {
+	first := firstCompletePeriod(g, statboard.Daily.Truncate(start))
+	after := statboard.Daily.Truncate(end).AddDate(0, 0, 1)
+
+	if g.Next(first).After(after) {
+		return time.Time{}, time.Time{}, false
+	}
+	last := first
+	for !g.Next(g.Next(last)).After(after) {
+		last = g.Next(last)
+	}
+	return first, last, true
+}
+
+// sumEvents adds the value of every event to the metric of its period
+func sumEvents(events []statboard.Event, metrics []statboard.Metric) []statboard.Metric {
+	for _, event := range events {
+		for i := 0; i < len(metrics); i++ {
+			met := &metrics[i]
+			if met.Granularity.Truncate(event.Timestamp).Equal(met.Date) {
+				met.Value += event.Value
+			}
+		}
+	}
+	return metrics
+}
+
+// ReadBackfillCSV reads the daily values of a metric from a data export with
+// one "date,value" row per day, dates formatted as 2006-01-02. A header row is
+// allowed.
+func ReadBackfillCSV(sourceName string, r io.Reader) ([]statboard.Event, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv")
+	}
+
+	var events []statboard.Event
+	for i, row := range rows {
+		if len(row) != 2 {
+			return nil, fmt.Errorf("line %d: expected 2 columns, got %d", i+1, len(row))
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d: failed to parse date", i+1))
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d: failed to parse value", i+1))
+		}
+		events = append(events, statboard.Event{
+			ID:        fmt.Sprintf("%s-import-%s", sourceName, date.Format("2006-01-02")),
+			Source:    sourceName,
+			Kind:      "import",
+			Timestamp: date,
+			Value:     value,
+		})
+	}
+	return events, nil
+}
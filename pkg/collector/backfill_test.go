@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletePeriods(t *testing.T) {
+	tt := []struct {
+		name        string
+		granularity statboard.Granularity
+		start       time.Time
+		end         time.Time
+		first       time.Time
+		last        time.Time
+		ok          bool
+	}{
+		{
+			name:        "whole months",
+			granularity: statboard.Monthly,
+			start:       time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			end:         time.Date(2018, 3, 31, 0, 0, 0, 0, time.UTC),
+			first:       time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			last:        time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC),
+			ok:          true,
+		},
+		{
+			name:        "partial months",
+			granularity: statboard.Monthly,
+			start:       time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC),
+			end:         time.Date(2018, 3, 30, 0, 0, 0, 0, time.UTC),
+			first:       time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+			last:        time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+			ok:          true,
+		},
+		{
+			name:        "no complete month",
+			granularity: statboard.Monthly,
+			start:       time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC),
+			end:         time.Date(2018, 2, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			first, last, ok := completePeriods(ts.granularity, ts.start, ts.end)
+			assert.Equal(t, ts.ok, ok)
+			assert.Equal(t, ts.first, first)
+			assert.Equal(t, ts.last, last)
+		})
+	}
+}
+
+func TestReadBackfillCSV(t *testing.T) {
+	events, err := ReadBackfillCSV("github", strings.NewReader("date,value\n2018-01-02,3\n2018-01-03, 4.5\n"))
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC), events[1].Timestamp)
+	assert.Equal(t, 4.5, events[1].Value)
+
+	_, err = ReadBackfillCSV("github", strings.NewReader("2018-01-02,3\nbad,4\n"))
+	assert.Error(t, err)
+}
+
+func TestRunnerBackfillEvents(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	err := s.WriteMetric(statboard.Metric{Name: "github.contributions", Date: jan, Value: 7, Granularity: statboard.Monthly})
+	assert.NoError(t, err)
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{"github": {"contributions": {}}}}
+	events := []statboard.Event{{Source: "github", Timestamp: feb.AddDate(0, 0, 3), Value: 5}}
+
+	res := NewRunner(cfg, s).BackfillEvents("github", "contributions", events, Options{Start: jan, End: feb.AddDate(0, 1, -1)})
+	assert.Equal(t, StatusSucceeded, res.Status)
+	assert.Equal(t, 1, res.Records)
+
+	// the stored January value is not replaced by an empty month
+	metrics, err := s.GetMetric("github.contributions", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	for _, met := range metrics {
+		if met.Date.Equal(jan) {
+			assert.Equal(t, 7.0, met.Value)
+		} else {
+			assert.Equal(t, 5.0, met.Value)
+		}
+	}
+}
+
+func TestRunnerBackfill_Unsupported(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{"goodreads": {"books_read": {}}}}
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return &fakeCollector{}, nil
+	}
+
+	res := r.Backfill(context.Background(), "goodreads", "books_read", Options{})
+	assert.Equal(t, StatusSkipped, res.Status)
+	assert.Error(t, res.Err)
+}
@@ -1,8 +1,31 @@
 package collector
 
-import "github.com/ajbosco/statboard/pkg/statboard"
+import (
+	"context"
+	"time"
 
-// Collector collects a metric data point
+	"github.com/ajbosco/statboard/pkg/statboard"
+)
+
+// Collector collects the raw events behind a metric
 type Collector interface {
-	Collect(metricName string, monthsBack int) ([]statboard.Metric, error)
+	Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error)
+}
+
+// CompleteCollector is implemented by collectors that return every event of
+// the returned kinds on each collection, whatever Options.Start. Stored events
+// of those kinds that are not collected again were removed at the source and
+// are deleted, and the periods that changed events moved out of or into are
+// rebuilt.
+type CompleteCollector interface {
+	CompleteKinds(metricName string) []string
+}
+
+// Aggregator adds raw events to the metric covering the period of each event
+type Aggregator func(events []statboard.Event, metrics []statboard.Metric) ([]statboard.Metric, error)
+
+// Options controls the range of a collection
+type Options struct {
+	Start time.Time
+	End   time.Time
 }
@@ -1,20 +1,60 @@
 package collector
 
 import (
+	"context"
 	"time"
 
 	"github.com/ajbosco/statboard/pkg/statboard"
 )
 
-func generateEmptyMetrics(metricName string, start time.Time, end time.Time) []statboard.Metric {
+func generateEmptyMetrics(metricName string, g statboard.Granularity, start time.Time, end time.Time) []statboard.Metric {
 	var metrics []statboard.Metric
 
-	// Generate statboard.Metric for each month in range
-	start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
-	for d := start; d.Before(end) || d.Equal(end); d = d.AddDate(0, 1, 0) {
-		met := statboard.Metric{Date: d, Name: metricName, Value: 0}
+	// Generate statboard.Metric for each period in range
+	for d := g.Truncate(start); d.Before(end) || d.Equal(end); d = g.Next(d) {
+		met := statboard.Metric{Date: d, Name: metricName, Value: 0, Granularity: g}
 		metrics = append(metrics, met)
 	}
 
 	return metrics
 }
+
+// collectionRange returns the first and last period to collect, ending with
+// the period containing yesterday
+func collectionRange(g statboard.Granularity, monthsBack int) (time.Time, time.Time) {
+	t := time.Now().AddDate(0, 0, -1)
+	end := g.Truncate(t)
+	start := g.Truncate(end.AddDate(0, -monthsBack, 0))
+
+	return start, end
+}
+
+// withContext runs fn and returns early if ctx is done first. It is used for
+// API clients that do not accept a context themselves.
+func withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// firstCompletePeriod returns the start of the first period that fully
+// follows t. A period starting exactly at t is complete.
+func firstCompletePeriod(g statboard.Granularity, t time.Time) time.Time {
+	start := g.Truncate(t)
+	if start.Equal(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)) {
+		return start
+	}
+	return g.Next(start)
+}
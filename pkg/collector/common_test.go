@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -12,51 +14,95 @@ func TestCommonGenerateEmptyMetrics(t *testing.T) {
 	testMonthDate := time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
 
 	tt := []struct {
-		name     string
-		start    time.Time
-		end      time.Time
-		expected []statboard.Metric
+		name        string
+		granularity statboard.Granularity
+		start       time.Time
+		end         time.Time
+		expected    []statboard.Metric
 	}{
 		{
-			name:  "monthly - range of dates",
-			start: testMonthDate.AddDate(0, -2, 0),
-			end:   testMonthDate,
+			name:        "monthly - range of dates",
+			granularity: statboard.Monthly,
+			start:       testMonthDate.AddDate(0, -2, 0),
+			end:         testMonthDate,
 			expected: []statboard.Metric{
 				{
-					Name:  "testMetric",
-					Date:  testMonthDate.AddDate(0, -2, 0),
-					Value: 0.0,
+					Name:        "testMetric",
+					Date:        testMonthDate.AddDate(0, -2, 0),
+					Value:       0.0,
+					Granularity: statboard.Monthly,
 				},
 				{
-					Name:  "testMetric",
-					Date:  testMonthDate.AddDate(0, -1, 0),
-					Value: 0.0,
+					Name:        "testMetric",
+					Date:        testMonthDate.AddDate(0, -1, 0),
+					Value:       0.0,
+					Granularity: statboard.Monthly,
 				},
 				{
-					Name:  "testMetric",
-					Date:  testMonthDate,
-					Value: 0.0,
+					Name:        "testMetric",
+					Date:        testMonthDate,
+					Value:       0.0,
+					Granularity: statboard.Monthly,
 				},
 			},
 		},
 		{
-			name:  "monthly - equal date",
-			start: testMonthDate,
-			end:   testMonthDate,
+			name:        "monthly - equal date",
+			granularity: statboard.Monthly,
+			start:       testMonthDate,
+			end:         testMonthDate,
 			expected: []statboard.Metric{
 				{
-					Name:  "testMetric",
-					Date:  testMonthDate,
-					Value: 0.0,
+					Name:        "testMetric",
+					Date:        testMonthDate,
+					Value:       0.0,
+					Granularity: statboard.Monthly,
 				},
 			},
 		},
+		{
+			name:        "daily - range of dates",
+			granularity: statboard.Daily,
+			start:       time.Date(2018, 1, 30, 15, 0, 0, 0, time.UTC),
+			end:         time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: []statboard.Metric{
+				{Name: "testMetric", Date: time.Date(2018, 1, 30, 0, 0, 0, 0, time.UTC), Granularity: statboard.Daily},
+				{Name: "testMetric", Date: time.Date(2018, 1, 31, 0, 0, 0, 0, time.UTC), Granularity: statboard.Daily},
+				{Name: "testMetric", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Granularity: statboard.Daily},
+			},
+		},
+		{
+			name:        "weekly - range of dates",
+			granularity: statboard.Weekly,
+			start:       time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC),
+			end:         time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC),
+			expected: []statboard.Metric{
+				{Name: "testMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Granularity: statboard.Weekly},
+				{Name: "testMetric", Date: time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC), Granularity: statboard.Weekly},
+			},
+		},
 	}
 
 	for _, ts := range tt {
 		t.Run(ts.name, func(t *testing.T) {
-			actual := generateEmptyMetrics("testMetric", ts.start, ts.end)
+			actual := generateEmptyMetrics("testMetric", ts.granularity, ts.start, ts.end)
 			assert.Equal(t, ts.expected, actual)
 		})
 	}
 }
+
+func TestCommonWithContext(t *testing.T) {
+	err := withContext(context.Background(), func() error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	block := make(chan struct{})
+	defer close(block)
+	err = withContext(ctx, func() error {
+		<-block
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
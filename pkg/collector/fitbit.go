@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -27,6 +28,16 @@ type FitbitCollector struct {
 	client  *http.Client
 }
 
+func init() {
+	Register("fitbit", func(cfg config.Config) (Collector, error) {
+		c, err := NewFitbitCollector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}, map[string]Aggregator{"steps": aggregateSteps})
+}
+
 // NewFitbitCollector parses config file and creates a new FitbitCollector
 func NewFitbitCollector(cfg config.Config) (*FitbitCollector, error) {
 	if cfg.Fitbit.ClientID == "" {
@@ -53,33 +64,26 @@ func NewFitbitCollector(cfg config.Config) (*FitbitCollector, error) {
 	return &FitbitCollector{baseURI: fitbitURI, client: client}, nil
 }
 
-// Collect returns metric from Fitbit API
-func (c *FitbitCollector) Collect(metricName string, monthsBack int) ([]statboard.Metric, error) {
-	var m []statboard.Metric
+// Collect returns the raw events behind a metric from Fitbit API
+func (c *FitbitCollector) Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	var e []statboard.Event
 	var err error
 
 	switch metricName {
 	case "steps":
-		m, err = c.getSteps(monthsBack)
+		e, err = c.getSteps(ctx, opts)
 	default:
 		err = fmt.Errorf("unsupported metric: %s", metricName)
 	}
 
-	return m, err
+	return e, err
 }
 
-func (c *FitbitCollector) getSteps(monthsBack int) ([]statboard.Metric, error) {
+func (c *FitbitCollector) getSteps(ctx context.Context, opts Options) ([]statboard.Event, error) {
 	var a FitbitActivities
 
-	// set range for which we will collect steps
-	end := time.Now().AddDate(0, 0, -1)
-	start := end.AddDate(0, -monthsBack, 0)
-
-	// create metric for each month in range
-	metrics := generateEmptyMetrics("fitbit.steps", start, end)
-
-	endpoint := fmt.Sprintf("activities/steps/date/%s/%s.json", start.Format("2006-01-02"), end.Format("2006-01-02"))
-	resp, err := doRequest(c.client, c.baseURI, endpoint)
+	endpoint := fmt.Sprintf("activities/steps/date/%s/%s.json", opts.Start.Format("2006-01-02"), opts.End.Format("2006-01-02"))
+	resp, err := doRequest(ctx, c.client, c.baseURI, endpoint)
 	if err != nil {
 		return nil, errors.Wrap(err, "collecting steps failed")
 	}
@@ -88,20 +92,21 @@ func (c *FitbitCollector) getSteps(monthsBack int) ([]statboard.Metric, error) {
 		return nil, errors.Wrap(err, "unmarshaling steps failed")
 	}
 
-	metrics, err = aggregateSteps(a.Steps, metrics)
+	events, err := stepsToEvents(a.Steps)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to aggregate step counts")
+		return nil, errors.Wrap(err, "failed to convert step counts")
 	}
-	return metrics, nil
+	return events, nil
 }
 
-func doRequest(client *http.Client, baseURI string, endpoint string) ([]byte, error) {
+func doRequest(ctx context.Context, client *http.Client, baseURI string, endpoint string) ([]byte, error) {
 	// Create the request.
 	uri := fmt.Sprintf("%s/%s", baseURI, strings.Trim(endpoint, "/"))
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("creating request to %s failed", uri))
 	}
+	req = req.WithContext(ctx)
 
 	// Do the request.
 	resp, err := client.Do(req)
@@ -124,22 +129,43 @@ func doRequest(client *http.Client, baseURI string, endpoint string) ([]byte, er
 	return b, nil
 }
 
-// aggregateSteps loops through daily step counts and aggregates them by month
-func aggregateSteps(steps []FitbitSteps, metrics []statboard.Metric) ([]statboard.Metric, error) {
+// stepsToEvents converts daily step counts into raw events
+func stepsToEvents(steps []FitbitSteps) ([]statboard.Event, error) {
+	var events []statboard.Event
 	for _, s := range steps {
+		// parse Activity Date into time.Time
+		dt, err := time.Parse("2006-01-02", s.ActivityDate)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing activity date failed")
+		}
+		// convert Steps string to float
+		steps, err := strconv.ParseFloat(s.Steps, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "converting steps to float failed")
+		}
+		events = append(events, statboard.Event{
+			ID:        fmt.Sprintf("fitbit-steps-%s", s.ActivityDate),
+			Source:    "fitbit",
+			Kind:      "steps",
+			Timestamp: dt,
+			Value:     steps,
+		})
+	}
+	return events, nil
+}
+
+// aggregateSteps loops through daily step events and aggregates them by the period of each metric
+func aggregateSteps(events []statboard.Event, metrics []statboard.Metric) ([]statboard.Metric, error) {
+	for _, e := range events {
+		if e.Kind != "steps" {
+			continue
+		}
 		for i := 0; i < len(metrics); i++ {
 			met := &metrics[i]
-			// parse Activity Date into time.Time
-			dt, err := time.Parse("2006-01-02", s.ActivityDate)
-			// convert Steps string to float
-			steps, err := strconv.ParseFloat(s.Steps, 64)
-			if err != nil {
-				return nil, errors.Wrap(err, "converting steps to float failed")
-			}
-			// get first of day of month for date of step activity
-			stepDate := time.Date(dt.Year(), dt.Month(), 1, 0, 0, 0, 0, time.UTC)
+			// get first day of the metric period for date of step activity
+			stepDate := met.Granularity.Truncate(e.Timestamp)
 			if stepDate.Equal(met.Date) {
-				met.Value = met.Value + steps
+				met.Value = met.Value + e.Value
 			}
 		}
 	}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -91,6 +92,47 @@ func TestFitbitAggregateEvents(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "daily granularity",
+			steps: []FitbitSteps{
+				{
+					ActivityDate: "2018-01-01",
+					Steps:        "100",
+				},
+				{
+					ActivityDate: "2018-01-02",
+					Steps:        "125",
+				},
+			},
+			metrics: []statboard.Metric{
+				{
+					Name:        "testMetric",
+					Date:        testActivityDate,
+					Value:       0.0,
+					Granularity: statboard.Daily,
+				},
+				{
+					Name:        "testMetric",
+					Date:        testActivityDate.AddDate(0, 0, 1),
+					Value:       0.0,
+					Granularity: statboard.Daily,
+				},
+			},
+			expected: []statboard.Metric{
+				{
+					Name:        "testMetric",
+					Date:        testActivityDate,
+					Value:       100.0,
+					Granularity: statboard.Daily,
+				},
+				{
+					Name:        "testMetric",
+					Date:        testActivityDate.AddDate(0, 0, 1),
+					Value:       125.0,
+					Granularity: statboard.Daily,
+				},
+			},
+		},
 		{
 			name:  "no events",
 			steps: []FitbitSteps{},
@@ -113,7 +155,9 @@ func TestFitbitAggregateEvents(t *testing.T) {
 
 	for _, ts := range tt {
 		t.Run(ts.name, func(t *testing.T) {
-			actual, err := aggregateSteps(ts.steps, ts.metrics)
+			events, err := stepsToEvents(ts.steps)
+			assert.NoError(t, err)
+			actual, err := aggregateSteps(events, ts.metrics)
 			assert.Equal(t, ts.expected, actual)
 			assert.NoError(t, err)
 		})
@@ -123,6 +167,25 @@ func TestFitbitAggregateEvents(t *testing.T) {
 func TestFitbitCollect_InvalidMetric(t *testing.T) {
 	c := FitbitCollector{}
 
-	_, err := c.Collect("fake_metric", 1)
+	_, err := c.Collect(context.Background(), "fake_metric", Options{})
+	assert.Error(t, err)
+}
+
+func TestFitbitStepsToEvents(t *testing.T) {
+	events, err := stepsToEvents([]FitbitSteps{{ActivityDate: "2018-01-01", Steps: "100"}})
+	assert.NoError(t, err)
+
+	expected := []statboard.Event{
+		{
+			ID:        "fitbit-steps-2018-01-01",
+			Source:    "fitbit",
+			Kind:      "steps",
+			Timestamp: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			Value:     100.0,
+		},
+	}
+	assert.Equal(t, expected, events)
+
+	_, err = stepsToEvents([]FitbitSteps{{ActivityDate: "2018-01-01", Steps: "many"}})
 	assert.Error(t, err)
 }
@@ -1,8 +1,11 @@
 package collector
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/ajbosco/statboard/pkg/config"
@@ -12,9 +15,13 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// githubGraphQLURI is the endpoint of the Github GraphQL API
+const githubGraphQLURI = "https://api.github.com/graphql"
+
 var (
-	_                  Collector = &GithubCollector{}
-	contributionEvents           = []string{
+	_                  Collector  = &GithubCollector{}
+	_                  Backfiller = &GithubCollector{}
+	contributionEvents            = []string{
 		"CommitCommentEvent",
 		"CreateEvent",
 		"RepositoryEvent",
@@ -28,8 +35,24 @@ var (
 
 // GithubCollector is used to collect metrics from Github API and implements Collector interface
 type GithubCollector struct {
-	username string
-	client   *github.Client
+	username   string
+	client     *github.Client
+	httpClient *http.Client
+	graphQLURI string
+}
+
+func init() {
+	Register("github", func(cfg config.Config) (Collector, error) {
+		c, err := NewGithubCollector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}, map[string]Aggregator{
+		"contributions": func(events []statboard.Event, metrics []statboard.Metric) ([]statboard.Metric, error) {
+			return aggregateEvents(events, metrics), nil
+		},
+	})
 }
 
 // NewGithubCollector parses config file and creates a new GithubCollector
@@ -41,79 +64,213 @@ func NewGithubCollector(cfg config.Config) (*GithubCollector, error) {
 		return nil, errors.New("'github.access_token' must be present in config")
 	}
 
-	client := github.NewClient(oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Github.AccessToken})))
+	httpClient := oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Github.AccessToken}))
 
-	return &GithubCollector{username: cfg.Github.Username, client: client}, nil
+	return &GithubCollector{
+		username:   cfg.Github.Username,
+		client:     github.NewClient(httpClient),
+		httpClient: httpClient,
+		graphQLURI: githubGraphQLURI,
+	}, nil
 }
 
-// Collect returns metric from Github API
-func (c *GithubCollector) Collect(metricName string, monthsBack int) ([]statboard.Metric, error) {
-	var m []statboard.Metric
+// Collect returns the raw events behind a metric from Github API
+func (c *GithubCollector) Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	var e []statboard.Event
 	var err error
 
 	switch metricName {
 	case "contributions":
-		m, err = c.getContributions(monthsBack)
+		e, err = c.getContributions(ctx, opts.Start)
 	default:
 		err = fmt.Errorf("unsupported metric: %s", metricName)
 	}
 
-	return m, err
+	return e, err
+}
+
+// Backfill returns daily contribution counts from the contributions calendar
+// of the Github GraphQL API, which reaches back further than the events API
+func (c *GithubCollector) Backfill(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	if metricName != "contributions" {
+		return nil, fmt.Errorf("unsupported metric: %s", metricName)
+	}
+
+	var events []statboard.Event
+	// the calendar can be queried for at most a year at a time
+	for from := opts.Start; !from.After(opts.End); from = from.AddDate(1, 0, 0) {
+		to := from.AddDate(1, 0, 0).Add(-time.Second)
+		if to.After(opts.End) {
+			to = opts.End
+		}
+		cal, err := c.fetchCalendar(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		calEvents, err := calendarToEvents(cal)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, calEvents...)
+	}
+	return events, nil
 }
 
-func (c *GithubCollector) getContributions(monthsBack int) ([]statboard.Metric, error) {
-	// set range for which we will collect steps
-	t := time.Now().AddDate(0, 0, -1)
-	end := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
-	start := end.AddDate(0, -monthsBack, 0)
+const contributionsQuery = `query($login: String!, $from: DateTime!, $to: DateTime!) {
+  user(login: $login) {
+    contributionsCollection(from: $from, to: $to) {
+      contributionCalendar { weeks { contributionDays { date contributionCount } } }
+    }
+  }
+}`
 
-	// create metric for each month in range
-	metrics := generateEmptyMetrics("github.contributions", start, end)
+// contributionCalendar is the response to contributionsQuery
+type contributionCalendar struct {
+	Data struct {
+		User struct {
+			ContributionsCollection struct {
+				ContributionCalendar struct {
+					Weeks []struct {
+						ContributionDays []struct {
+							Date              string `json:"date"`
+							ContributionCount int    `json:"contributionCount"`
+						} `json:"contributionDays"`
+					} `json:"weeks"`
+				} `json:"contributionCalendar"`
+			} `json:"contributionsCollection"`
+		} `json:"user"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (c *GithubCollector) fetchCalendar(ctx context.Context, from time.Time, to time.Time) (contributionCalendar, error) {
+	var cal contributionCalendar
 
-	events, err := c.fetchEvents()
+	body, err := json.Marshal(map[string]interface{}{
+		"query": contributionsQuery,
+		"variables": map[string]string{
+			"login": c.username,
+			"from":  from.Format(time.RFC3339),
+			"to":    to.Format(time.RFC3339),
+		},
+	})
 	if err != nil {
-		return nil, err
+		return cal, errors.Wrap(err, "failed to marshal contributions query")
 	}
 
-	metrics = aggregateEvents(events, metrics)
+	req, err := http.NewRequest(http.MethodPost, c.graphQLURI, bytes.NewReader(body))
+	if err != nil {
+		return cal, errors.Wrap(err, "failed to create contributions request")
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return cal, errors.Wrap(err, "fetching github contributions calendar failed")
+	}
+	defer resp.Body.Close()
 
-	return metrics, nil
+	if resp.StatusCode != http.StatusOK {
+		return cal, fmt.Errorf("fetching github contributions calendar failed with status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cal); err != nil {
+		return cal, errors.Wrap(err, "failed to decode contributions calendar")
+	}
+	if len(cal.Errors) > 0 {
+		return cal, fmt.Errorf("github contributions query failed: %s", cal.Errors[0].Message)
+	}
+	return cal, nil
+}
+
+// calendarToEvents converts the days of a contributions calendar into events
+func calendarToEvents(cal contributionCalendar) ([]statboard.Event, error) {
+	var events []statboard.Event
+	for _, week := range cal.Data.User.ContributionsCollection.ContributionCalendar.Weeks {
+		for _, day := range week.ContributionDays {
+			date, err := time.Parse("2006-01-02", day.Date)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse contribution date")
+			}
+			events = append(events, statboard.Event{
+				ID:        fmt.Sprintf("github-calendar-%s", day.Date),
+				Source:    "github",
+				Kind:      "contribution_day",
+				Timestamp: date,
+				Value:     float64(day.ContributionCount),
+			})
+		}
+	}
+	return events, nil
+}
+
+func (c *GithubCollector) getContributions(ctx context.Context, since time.Time) ([]statboard.Event, error) {
+	events, err := c.fetchEvents(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return githubToEvents(events), nil
 }
 
-func (c *GithubCollector) fetchEvents() ([]*github.Event, error) {
-	var contribEvents []*github.Event
+// fetchEvents returns the events the API still has for the user, newest first,
+// stopping after the first page that reaches back past since. All event types
+// are kept so contributions can be recounted from the stored events.
+func (c *GithubCollector) fetchEvents(ctx context.Context, since time.Time) ([]*github.Event, error) {
+	var allEvents []*github.Event
 
 	opt := &github.ListOptions{}
 	for page := 1; ; page++ {
 		opt.Page = page
-		events, resp, err := c.client.Activity.ListEventsPerformedByUser(context.Background(), c.username, true, opt)
+		events, resp, err := c.client.Activity.ListEventsPerformedByUser(ctx, c.username, true, opt)
 		if err != nil {
 			return nil, errors.Wrap(err, "collecting github events failed")
 		}
 
-		for _, event := range events {
-			// Only count activity for contribution events
-			if isContribEvent(*event.Type) {
-				contribEvents = append(contribEvents, event)
-			}
-		}
+		allEvents = append(allEvents, events...)
 
-		if resp.NextPage == 0 {
+		if resp.NextPage == 0 || reachedSince(events, since) {
 			break
 		}
 	}
 
-	return contribEvents, nil
+	return allEvents, nil
+}
+
+// reachedSince reports whether a page of events, ordered newest first, ends before since
+func reachedSince(events []*github.Event, since time.Time) bool {
+	if len(events) == 0 {
+		return true
+	}
+	return events[len(events)-1].GetCreatedAt().Before(since)
+}
+
+// githubToEvents converts Github events into raw events
+func githubToEvents(events []*github.Event) []statboard.Event {
+	var rawEvents []statboard.Event
+	for _, event := range events {
+		e := statboard.Event{
+			ID:        fmt.Sprintf("github-%s", event.GetID()),
+			Source:    "github",
+			Kind:      event.GetType(),
+			Timestamp: event.GetCreatedAt(),
+			Value:     1,
+		}
+		if event.Repo != nil {
+			e.Attributes = map[string]string{"repo": event.Repo.GetName()}
+		}
+		rawEvents = append(rawEvents, e)
+	}
+	return rawEvents
 }
 
-func aggregateEvents(events []*github.Event, metrics []statboard.Metric) []statboard.Metric {
+func aggregateEvents(events []statboard.Event, metrics []statboard.Metric) []statboard.Metric {
 	for _, event := range events {
 		// Only count activity for contribution events
-		if isContribEvent(*event.Type) {
+		if isContribEvent(event.Kind) {
 			for i := 0; i < len(metrics); i++ {
 				met := &metrics[i]
-				// get first of day of month for date of step activity
-				eventDt := time.Date(event.CreatedAt.Year(), event.CreatedAt.Month(), 1, 0, 0, 0, 0, time.UTC)
+				// get first day of the metric period for date of event
+				eventDt := met.Granularity.Truncate(event.Timestamp)
 				if eventDt.Equal(met.Date) {
 					met.Value++
 				}
@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -105,7 +107,7 @@ func TestGithubAggregateEvents(t *testing.T) {
 
 	for _, ts := range tt {
 		t.Run(ts.name, func(t *testing.T) {
-			actual := aggregateEvents(ts.events, ts.metrics)
+			actual := aggregateEvents(githubToEvents(ts.events), ts.metrics)
 			assert.Equal(t, ts.expected, actual)
 		})
 	}
@@ -140,6 +142,49 @@ func TestGithubIsContribEvent(t *testing.T) {
 func TestGithubCollect_InvalidMetric(t *testing.T) {
 	c := GithubCollector{}
 
-	_, err := c.Collect("fake_metric", 1)
+	_, err := c.Collect(context.Background(), "fake_metric", Options{})
 	assert.Error(t, err)
 }
+
+func TestGithubToEvents(t *testing.T) {
+	testID := "123"
+	testEventType := "WatchEvent"
+	testRepoName := "ajbosco/statboard"
+	testCreatedAt := time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+
+	actual := githubToEvents([]*github.Event{
+		{
+			ID:        &testID,
+			Type:      &testEventType,
+			CreatedAt: &testCreatedAt,
+			Repo:      &github.Repository{Name: &testRepoName},
+		},
+	})
+
+	expected := []statboard.Event{
+		{
+			ID:         "github-123",
+			Source:     "github",
+			Kind:       "WatchEvent",
+			Timestamp:  testCreatedAt,
+			Value:      1.0,
+			Attributes: map[string]string{"repo": "ajbosco/statboard"},
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestGithubCalendarToEvents(t *testing.T) {
+	var cal contributionCalendar
+	err := json.Unmarshal([]byte(`{"data":{"user":{"contributionsCollection":{"contributionCalendar":{"weeks":[
+		{"contributionDays":[{"date":"2018-01-01","contributionCount":0},{"date":"2018-01-02","contributionCount":4}]}
+	]}}}}}`), &cal)
+	assert.NoError(t, err)
+
+	events, err := calendarToEvents(cal)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "github-calendar-2018-01-02", events[1].ID)
+	assert.Equal(t, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), events[1].Timestamp)
+	assert.Equal(t, 4.0, events[1].Value)
+}
@@ -1,8 +1,10 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ajbosco/reads/goodreads"
@@ -12,12 +14,27 @@ import (
 )
 
 var (
-	_ Collector = &GoodreadsCollector{}
+	_ Collector         = &GoodreadsCollector{}
+	_ CompleteCollector = &GoodreadsCollector{}
 )
 
 // GoodreadsCollector is used to collect metrics from Goodreads API and implements Collector interface
 type GoodreadsCollector struct {
 	client *goodreads.Client
+
+	// the read shelf is fetched once and shared by every metric
+	mu    sync.Mutex
+	books []goodreads.Book
+}
+
+func init() {
+	Register("goodreads", func(cfg config.Config) (Collector, error) {
+		c, err := NewGoodreadsCollector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}, map[string]Aggregator{"books_read": aggregateBooks, "pages_read": aggregatePages})
 }
 
 // NewGoodreadsCollector parses config file and creates a new GoodreadsCollector
@@ -54,75 +71,78 @@ func NewGoodreadsCollector(cfg config.Config) (*GoodreadsCollector, error) {
 	return &GoodreadsCollector{client: client}, nil
 }
 
-// Collect returns metric from Goodreads API
-func (c *GoodreadsCollector) Collect(metricName string, monthsBack int) ([]statboard.Metric, error) {
-	var m []statboard.Metric
+// Collect returns the raw events behind a metric from Goodreads API
+func (c *GoodreadsCollector) Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	var e []statboard.Event
 	var err error
 
 	switch metricName {
-	case "books_read":
-		m, err = c.getBooksRead(monthsBack)
-	case "pages_read":
-		m, err = c.getPagesRead(monthsBack)
+	case "books_read", "pages_read":
+		e, err = c.getReads(ctx)
 	default:
 		err = fmt.Errorf("unsupported metric: %s", metricName)
 	}
 
-	return m, err
+	return e, err
 }
 
-func (c *GoodreadsCollector) getBooksRead(monthsBack int) ([]statboard.Metric, error) {
-	// set range for which we will collect steps
-	t := time.Now().AddDate(0, 0, -1)
-	end := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
-	start := end.AddDate(0, -monthsBack, 0)
-
-	// create metric for each month in range
-	metrics := generateEmptyMetrics("goodreads.books_read", start, end)
+// CompleteKinds returns the read events, which are always collected from the
+// full read shelf
+func (c *GoodreadsCollector) CompleteKinds(metricName string) []string {
+	return []string{"read"}
+}
 
-	books, err := c.fetchBooks()
+// getReads returns the read events of the whole read shelf. The shelf cannot
+// be filtered by date, and returning all of it lets reads whose date was
+// edited to before the checkpoint be moved.
+func (c *GoodreadsCollector) getReads(ctx context.Context) ([]statboard.Event, error) {
+	books, err := c.readShelf(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch books")
 	}
 
-	metrics, err = aggregateBooks(books, metrics)
+	events, err := booksToEvents(books)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to aggregate book counts")
+		return nil, errors.Wrap(err, "failed to convert books")
 	}
 
-	return metrics, nil
+	return events, nil
 }
 
-func (c *GoodreadsCollector) getPagesRead(monthsBack int) ([]statboard.Metric, error) {
-	// set range for which we will collect steps
-	t := time.Now().AddDate(0, 0, -1)
-	end := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
-	start := end.AddDate(0, -monthsBack, 0)
-
-	// create metric for each month in range
-	metrics := generateEmptyMetrics("goodreads.pages_read", start, end)
+// readShelf returns the books on the read shelf, fetching them on first use.
+// The Goodreads API cannot filter the shelf by date.
+func (c *GoodreadsCollector) readShelf(ctx context.Context) ([]goodreads.Book, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	books, err := c.fetchBooks()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch books")
+	if c.books != nil {
+		return c.books, nil
 	}
-
-	metrics, err = aggregatePages(books, metrics)
+	books, err := c.fetchBooks(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to aggregate page counts")
+		return nil, err
 	}
-
-	return metrics, nil
+	c.books = books
+	return books, nil
 }
 
-func (c *GoodreadsCollector) fetchBooks() ([]goodreads.Book, error) {
-
-	user, err := c.client.GetCurrentUserID()
+func (c *GoodreadsCollector) fetchBooks(ctx context.Context) ([]goodreads.Book, error) {
+	var user *goodreads.User
+	err := withContext(ctx, func() error {
+		var err error
+		user, err = c.client.GetCurrentUserID()
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get Goodreads user_id")
 	}
 
-	books, err := c.client.ListShelfBooks("read", user.ID)
+	var books []goodreads.Book
+	err = withContext(ctx, func() error {
+		var err error
+		books, err = c.client.ListShelfBooks("read", user.ID)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get Goodreads books")
 	}
@@ -130,21 +150,42 @@ func (c *GoodreadsCollector) fetchBooks() ([]goodreads.Book, error) {
 	return books, nil
 }
 
-func aggregateBooks(books []goodreads.Book, metrics []statboard.Metric) ([]statboard.Metric, error) {
+// booksToEvents converts read books into raw events. Books without a read date
+// are skipped. A book is on the read shelf once, so its event is keyed on the
+// book alone and replaced when the read date is edited.
+func booksToEvents(books []goodreads.Book) ([]statboard.Event, error) {
+	var events []statboard.Event
 	for _, book := range books {
+		if book.ReadAt == "" {
+			continue
+		}
+		// convert goodreads string date into time.Time
+		readDate, err := time.Parse(time.RubyDate, book.ReadAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse ReadAt date")
+		}
+		events = append(events, statboard.Event{
+			ID:         fmt.Sprintf("goodreads-%s", book.ID),
+			Source:     "goodreads",
+			Kind:       "read",
+			Timestamp:  readDate,
+			Value:      1,
+			Attributes: map[string]string{"title": book.Title, "pages": book.Pages},
+		})
+	}
+	return events, nil
+}
+
+func aggregateBooks(events []statboard.Event, metrics []statboard.Metric) ([]statboard.Metric, error) {
+	for _, event := range events {
 		for i := 0; i < len(metrics); i++ {
 			met := &metrics[i]
-			if book.ReadAt == "" {
+			if event.Kind != "read" {
 				continue
 			}
-			// convert goodreads string date into time.Time
-			readDate, err := time.Parse(time.RubyDate, book.ReadAt)
-			if err != nil {
-				return metrics, errors.Wrap(err, "failed to parse ReadAt date")
-			}
-			// get first of read month since this is a monthly metric
-			readMonth := time.Date(readDate.Year(), readDate.Month(), 1, 0, 0, 0, 0, time.UTC)
-			if readMonth.Equal(met.Date) {
+			// get first day of the metric period for read date
+			readPeriod := met.Granularity.Truncate(event.Timestamp)
+			if readPeriod.Equal(met.Date) {
 				met.Value++
 			}
 		}
@@ -152,26 +193,21 @@ func aggregateBooks(books []goodreads.Book, metrics []statboard.Metric) ([]statb
 	return metrics, nil
 }
 
-func aggregatePages(books []goodreads.Book, metrics []statboard.Metric) ([]statboard.Metric, error) {
-	for _, book := range books {
+func aggregatePages(events []statboard.Event, metrics []statboard.Metric) ([]statboard.Metric, error) {
+	for _, event := range events {
 		for i := 0; i < len(metrics); i++ {
 			met := &metrics[i]
-			if book.ReadAt == "" || book.Pages == "" {
+			if event.Kind != "read" || event.Attributes["pages"] == "" {
 				continue
 			}
-			// convert goodreads string date into time.Time
-			readDate, err := time.Parse(time.RubyDate, book.ReadAt)
-			if err != nil {
-				return metrics, errors.Wrap(err, "failed to parse ReadAt date")
-			}
-			// get first of read month since this is a monthly metric
-			readMonth := time.Date(readDate.Year(), readDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+			// get first day of the metric period for read date
+			readPeriod := met.Granularity.Truncate(event.Timestamp)
 			// convert pages to float for metric Value
-			pages, err := strconv.ParseFloat(book.Pages, 64)
+			pages, err := strconv.ParseFloat(event.Attributes["pages"], 64)
 			if err != nil {
 				return metrics, errors.Wrap(err, "failed to convert Pages to float64")
 			}
-			if readMonth.Equal(met.Date) {
+			if readPeriod.Equal(met.Date) {
 				met.Value = met.Value + pages
 			}
 		}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -97,7 +98,9 @@ func TestGoodreadsAggregateBooks(t *testing.T) {
 
 	for _, ts := range tt {
 		t.Run(ts.name, func(t *testing.T) {
-			actual, _ := aggregateBooks(ts.books, ts.metrics)
+			events, err := booksToEvents(ts.books)
+			assert.NoError(t, err)
+			actual, _ := aggregateBooks(events, ts.metrics)
 			assert.Equal(t, ts.expected, actual)
 		})
 	}
@@ -198,7 +201,9 @@ func TestGoodreadsAggregatePages(t *testing.T) {
 
 	for _, ts := range tt {
 		t.Run(ts.name, func(t *testing.T) {
-			actual, _ := aggregatePages(ts.books, ts.metrics)
+			events, err := booksToEvents(ts.books)
+			assert.NoError(t, err)
+			actual, _ := aggregatePages(events, ts.metrics)
 			assert.Equal(t, ts.expected, actual)
 		})
 	}
@@ -207,6 +212,31 @@ func TestGoodreadsAggregatePages(t *testing.T) {
 func TestGoodreadsCollect_InvalidMetric(t *testing.T) {
 	c := GoodreadsCollector{}
 
-	_, err := c.Collect("fake_metric", 1)
+	_, err := c.Collect(context.Background(), "fake_metric", Options{})
+	assert.Error(t, err)
+}
+
+func TestGoodreadsBooksToEvents(t *testing.T) {
+	books := []goodreads.Book{
+		{
+			ID:     "1",
+			Title:  "testBook",
+			Pages:  "100",
+			ReadAt: "Mon Jan 02 15:04:05 -0700 2006",
+		},
+		{
+			ID:    "2",
+			Title: "unreadBook",
+		},
+	}
+
+	events, err := booksToEvents(books)
+	assert.NoError(t, err)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, "goodreads-1", events[0].ID)
+	assert.Equal(t, map[string]string{"title": "testBook", "pages": "100"}, events[0].Attributes)
+
+	_, err = booksToEvents([]goodreads.Book{{ReadAt: "yesterday"}})
 	assert.Error(t, err)
 }
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+)
+
+// Factory creates a Collector from the Statboard config
+type Factory func(cfg config.Config) (Collector, error)
+
+// source contains the registered factory and metric aggregators for a metric source
+type source struct {
+	factory     Factory
+	aggregators map[string]Aggregator
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]source)
+)
+
+// Register makes a metric source available to the collector under the given name.
+// Each supported metric maps to the Aggregator that builds it from the source's raw events.
+// It panics if the name is registered twice or the factory is nil.
+func Register(name string, factory Factory, aggregators map[string]Aggregator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("collector: Register factory for %q is nil", name))
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("collector: Register called twice for source %q", name))
+	}
+
+	registry[name] = source{factory: factory, aggregators: aggregators}
+}
+
+// New creates the Collector registered for the given source
+func New(sourceName string, cfg config.Config) (Collector, error) {
+	registryMu.RLock()
+	src, ok := registry[sourceName]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported source %q, valid sources are: %s", sourceName, strings.Join(Sources(), ", "))
+	}
+
+	c, err := src.factory(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to create %q collector", sourceName))
+	}
+	return c, nil
+}
+
+// Sources returns the sorted names of all registered sources
+func Sources() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Metrics returns the sorted metric names supported by the given source
+func Metrics(sourceName string) []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var metrics []string
+	for name := range registry[sourceName].aggregators {
+		metrics = append(metrics, name)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// aggregatorFor returns the Aggregator registered for a metric of the source
+func aggregatorFor(sourceName string, metName string) (Aggregator, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	agg, ok := registry[sourceName].aggregators[metName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported metric %q for source %q", metName, sourceName)
+	}
+	return agg, nil
+}
+
+// Validate checks that every configured source and metric has been registered
+func Validate(metrics map[string]map[string]config.MetricConfig) error {
+	var problems []string
+
+	for _, sourceName := range sortedKeys(metrics) {
+		registryMu.RLock()
+		_, ok := registry[sourceName]
+		registryMu.RUnlock()
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unsupported source %q, valid sources are: %s", sourceName, strings.Join(Sources(), ", ")))
+			continue
+		}
+
+		for metName, metCfg := range metrics[sourceName] {
+			if !supportsMetric(sourceName, metName) {
+				problems = append(problems, fmt.Sprintf("unsupported metric %q for source %q, valid metrics are: %s", metName, sourceName, strings.Join(Metrics(sourceName), ", ")))
+			}
+			if _, err := statboard.ParseGranularity(string(metCfg.Granularity)); err != nil {
+				problems = append(problems, fmt.Sprintf("metric %q: %s", metricName(sourceName, metName), err))
+			}
+			for _, rule := range metCfg.Retention {
+				if err := validateRetention(rule); err != nil {
+					problems = append(problems, fmt.Sprintf("metric %q: %s", metricName(sourceName, metName), err))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// supportsMetric reports whether the metric was registered for the source
+func supportsMetric(sourceName string, metricName string) bool {
+	_, err := aggregatorFor(sourceName, metricName)
+	return err == nil
+}
+
+// sortedKeys returns the keys of the metrics config in a stable order
+func sortedKeys(metrics map[string]map[string]config.MetricConfig) []string {
+	var keys []string
+	for k := range metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrySources(t *testing.T) {
+	assert.Equal(t, []string{"fitbit", "github", "goodreads"}, Sources())
+	assert.Equal(t, []string{"books_read", "pages_read"}, Metrics("goodreads"))
+	assert.Empty(t, Metrics("fake_source"))
+}
+
+func TestRegistryNew_UnsupportedSource(t *testing.T) {
+	_, err := New("fake_source", config.Config{})
+	assert.EqualError(t, err, `unsupported source "fake_source", valid sources are: fitbit, github, goodreads`)
+}
+
+func TestRegistryNew_FactoryError(t *testing.T) {
+	c, err := New("github", config.Config{})
+	assert.Error(t, err)
+	assert.Nil(t, c)
+}
+
+func TestRegistryValidate(t *testing.T) {
+	tt := []struct {
+		name     string
+		metrics  map[string]map[string]config.MetricConfig
+		expected string
+	}{
+		{
+			name: "valid metrics",
+			metrics: map[string]map[string]config.MetricConfig{
+				"fitbit":    {"steps": {}},
+				"goodreads": {"books_read": {}, "pages_read": {}},
+			},
+		},
+		{
+			name: "unsupported source",
+			metrics: map[string]map[string]config.MetricConfig{
+				"strava": {"rides": {}},
+			},
+			expected: `unsupported source "strava", valid sources are: fitbit, github, goodreads`,
+		},
+		{
+			name: "unsupported metric",
+			metrics: map[string]map[string]config.MetricConfig{
+				"github": {"stars": {}},
+			},
+			expected: `unsupported metric "stars" for source "github", valid metrics are: contributions`,
+		},
+		{
+			name: "unsupported granularity",
+			metrics: map[string]map[string]config.MetricConfig{
+				"fitbit": {"steps": {Granularity: "hour"}},
+			},
+			expected: `metric "fitbit.steps": unsupported granularity "hour", valid granularities are: day, week, month, year`,
+		},
+		{
+			name: "valid retention",
+			metrics: map[string]map[string]config.MetricConfig{
+				"fitbit": {"steps": {Retention: []config.RetentionConfig{{Granularity: "day", KeepDays: 90, RollupTo: "month"}}}},
+			},
+		},
+		{
+			name: "retention without keep_days",
+			metrics: map[string]map[string]config.MetricConfig{
+				"fitbit": {"steps": {Retention: []config.RetentionConfig{{Granularity: "day"}}}},
+			},
+			expected: `metric "fitbit.steps": retention of day rows must keep them for at least one day`,
+		},
+		{
+			name: "retention rollup not aligned",
+			metrics: map[string]map[string]config.MetricConfig{
+				"fitbit": {"steps": {Retention: []config.RetentionConfig{{Granularity: "week", KeepDays: 90, RollupTo: "month"}}}},
+			},
+			expected: `metric "fitbit.steps": week rows cannot be rolled up to month`,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			err := Validate(ts.metrics)
+			if ts.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, ts.expected)
+		})
+	}
+}
+
+func TestRegistryRegister_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("fitbit", func(cfg config.Config) (Collector, error) { return nil, nil }, map[string]Aggregator{"steps": aggregateSteps})
+	})
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/sirupsen/logrus"
+)
+
+// validateRetention checks that a retention rule can be applied
+func validateRetention(rule config.RetentionConfig) error {
+	g, err := statboard.ParseGranularity(string(rule.Granularity))
+	if err != nil {
+		return err
+	}
+	if rule.KeepDays <= 0 {
+		return fmt.Errorf("retention of %s rows must keep them for at least one day", g)
+	}
+	if rule.RollupTo == "" {
+		return nil
+	}
+	if _, err := statboard.ParseGranularity(string(rule.RollupTo)); err != nil {
+		return err
+	}
+	if !g.RollsUpTo(rule.RollupTo) {
+		return fmt.Errorf("%s rows cannot be rolled up to %s", g, rule.RollupTo)
+	}
+	return nil
+}
+
+// Compact applies the retention rules of every configured metric. Rows older
+// than a rule's keep_days are summed into rollup periods and then deleted.
+// Only whole rollup periods are compacted, so compacting again changes nothing.
+func (r *Runner) Compact(now time.Time) *RunSummary {
+	summary := &RunSummary{}
+
+	for _, sourceName := range sortedKeys(r.cfg.Metrics) {
+		for metName, metCfg := range r.cfg.Metrics[sourceName] {
+			if len(metCfg.Retention) == 0 {
+				continue
+			}
+			res := MetricResult{Metric: metricName(sourceName, metName), Status: StatusSucceeded}
+			for _, rule := range metCfg.Retention {
+				n, err := r.compactMetric(res.Metric, rule, now)
+				res.Records += n
+				if err != nil {
+					res.Status = StatusFailed
+					res.Err = err
+					break
+				}
+			}
+			if res.Records > 0 {
+				logrus.Info(fmt.Sprintf("compacted %d %q records", res.Records, res.Metric))
+			}
+			summary.add(res)
+		}
+	}
+
+	return summary
+}
+
+// compactMetric applies a single retention rule and returns how many rows were deleted
+func (r *Runner) compactMetric(name string, rule config.RetentionConfig, now time.Time) (int, error) {
+	g := rule.Granularity.OrDefault()
+	cutoff := g.Truncate(now.AddDate(0, 0, -rule.KeepDays))
+	if rule.RollupTo != "" {
+		cutoff = rule.RollupTo.Truncate(cutoff)
+	}
+
+	expired, err := r.store.GetMetricRange(name, g, time.Time{}, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	// Write the rollups before deleting, so an interrupted compaction is
+	// completed by the next one
+	if rule.RollupTo != "" {
+		rollups, err := r.rollup(name, rule.RollupTo, expired)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.store.WriteMetrics(rollups); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.store.DeleteMetricRange(name, g, time.Time{}, cutoff)
+}
+
+// rollup sums metrics into the periods of the coarser granularity. A stored
+// rollup that is larger is kept, so rows re-collected after an earlier
+// compaction never shrink it.
+func (r *Runner) rollup(name string, g statboard.Granularity, metrics []statboard.Metric) ([]statboard.Metric, error) {
+	sums := make(map[time.Time]float64)
+	for _, m := range metrics {
+		sums[g.Truncate(m.Date)] += m.Value
+	}
+
+	var rollups []statboard.Metric
+	for date, value := range sums {
+		stored, err := r.store.GetMetricRange(name, g, date, g.Next(date))
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) > 0 && stored[0].Value > value {
+			value = stored[0].Value
+		}
+		rollups = append(rollups, statboard.Metric{Name: name, Date: date, Value: value, Granularity: g})
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Date.Before(rollups[j].Date) })
+	return rollups, nil
+}
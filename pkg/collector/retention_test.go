@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerCompact(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	// daily steps from April 1 to May 20
+	var metrics []statboard.Metric
+	for d := time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2018, 5, 21, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		metrics = append(metrics, statboard.Metric{Name: "fitbit.steps", Date: d, Value: 10.0, Granularity: statboard.Daily})
+	}
+	assert.NoError(t, s.WriteMetrics(metrics))
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"fitbit": {
+			"steps": {Granularity: statboard.Daily, Retention: []config.RetentionConfig{
+				{Granularity: statboard.Daily, KeepDays: 30, RollupTo: statboard.Monthly},
+			}},
+		},
+		"goodreads": {"books_read": {}},
+	}}
+	r := NewRunner(cfg, s)
+	now := time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	summary := r.Compact(now)
+	assert.NoError(t, summary.Err())
+	assert.Len(t, summary.Results, 1)
+	assert.Equal(t, 30, summary.Results[0].Records)
+
+	// May is not entirely older than 30 days, so it keeps its daily rows
+	daily, err := s.GetMetric("fitbit.steps", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, daily, 20)
+	assert.Equal(t, time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC), daily[0].Date)
+
+	monthly, err := s.GetMetric("fitbit.steps", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, monthly, 1)
+	assert.Equal(t, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC), monthly[0].Date)
+	assert.Equal(t, 300.0, monthly[0].Value)
+
+	// compacting again changes nothing
+	summary = r.Compact(now)
+	assert.NoError(t, summary.Err())
+	assert.Equal(t, 0, summary.Results[0].Records)
+
+	monthly, err = s.GetMetric("fitbit.steps", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, monthly, 1)
+	assert.Equal(t, 300.0, monthly[0].Value)
+}
+
+func TestRunnerCompact_DeleteOnly(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	assert.NoError(t, s.WriteMetrics([]statboard.Metric{
+		{Name: "fitbit.steps", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 10.0, Granularity: statboard.Weekly},
+		{Name: "fitbit.steps", Date: time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC), Value: 20.0, Granularity: statboard.Weekly},
+	}))
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"fitbit": {"steps": {Granularity: statboard.Weekly, Retention: []config.RetentionConfig{
+			{Granularity: statboard.Weekly, KeepDays: 30},
+		}}},
+	}}
+	summary := NewRunner(cfg, s).Compact(time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, summary.Err())
+	assert.Equal(t, 1, summary.Results[0].Records)
+
+	weekly, err := s.GetMetric("fitbit.steps", statboard.Weekly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, weekly, 1)
+	assert.Equal(t, 20.0, weekly[0].Value)
+}
@@ -0,0 +1,416 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/ajbosco/statboard/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Status is the outcome of collecting a single metric
+type Status string
+
+const (
+	// StatusSucceeded means the metric was collected and written
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed means collecting or writing the metric failed
+	StatusFailed Status = "failed"
+	// StatusSkipped means the metric was not collected
+	StatusSkipped Status = "skipped"
+)
+
+// runHistory is the number of collection runs kept for each metric
+const runHistory = 100
+
+// MetricResult contains the outcome of collecting a single metric
+type MetricResult struct {
+	Metric  string
+	Status  Status
+	Records int
+	Err     error
+}
+
+// RunSummary contains the results of every metric in a collection run
+type RunSummary struct {
+	mu      sync.Mutex
+	Results []MetricResult
+}
+
+func (s *RunSummary) add(r MetricResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Results = append(s.Results, r)
+}
+
+// Count returns the number of results with the given status
+func (s *RunSummary) Count(status Status) int {
+	var n int
+	for _, r := range s.Results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error describing every metric that failed or was skipped
+func (s *RunSummary) Err() error {
+	var msgs []string
+	for _, r := range s.Results {
+		if r.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s %s: %v", r.Metric, r.Status, r.Err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Log writes the outcome of every metric in the run
+func (s *RunSummary) Log() {
+	sort.Slice(s.Results, func(i, j int) bool { return s.Results[i].Metric < s.Results[j].Metric })
+	for _, r := range s.Results {
+		entry := logrus.WithFields(logrus.Fields{"metric": r.Metric, "status": r.Status, "records": r.Records})
+		if r.Err != nil {
+			entry.Error(r.Err)
+			continue
+		}
+		entry.Info("collection finished")
+	}
+	logrus.Info(fmt.Sprintf("collection run finished: %d succeeded, %d failed, %d skipped",
+		s.Count(StatusSucceeded), s.Count(StatusFailed), s.Count(StatusSkipped)))
+}
+
+// Runner collects the configured metrics and writes them to the store.
+// A failing source or metric does not stop the others from being collected.
+type Runner struct {
+	cfg          config.Config
+	store        storage.Store
+	newCollector func(sourceName string, cfg config.Config) (Collector, error)
+	fullRefresh  bool
+}
+
+// NewRunner creates a Runner for the metrics in the config
+func NewRunner(cfg config.Config, store storage.Store) *Runner {
+	return &Runner{cfg: cfg, store: store, newCollector: New}
+}
+
+// SetFullRefresh makes the Runner ignore checkpoints and collect the full
+// collect_months_back range of every metric
+func (r *Runner) SetFullRefresh(full bool) {
+	r.fullRefresh = full
+}
+
+// Run collects every configured metric and returns a summary of the results
+func (r *Runner) Run(ctx context.Context) *RunSummary {
+	summary := &RunSummary{}
+
+	var wg sync.WaitGroup
+	for sourceName, metCfgs := range r.cfg.Metrics {
+		wg.Add(1)
+		go func(sourceName string, metCfgs map[string]config.MetricConfig) {
+			defer wg.Done()
+			r.runSource(ctx, sourceName, metCfgs, summary)
+		}(sourceName, metCfgs)
+	}
+	wg.Wait()
+
+	return summary
+}
+
+// RunMetric collects a single configured metric and returns its result
+func (r *Runner) RunMetric(ctx context.Context, sourceName string, metName string) MetricResult {
+	return r.RunSource(ctx, sourceName, []string{metName}).Results[0]
+}
+
+// RunSource collects the given configured metrics of a source with a single
+// collector and returns a summary of the results
+func (r *Runner) RunSource(ctx context.Context, sourceName string, metNames []string) *RunSummary {
+	summary := &RunSummary{}
+	metCfgs := make(map[string]config.MetricConfig)
+	for _, metName := range metNames {
+		metCfgs[metName] = r.cfg.Metrics[sourceName][metName]
+	}
+	r.runSource(ctx, sourceName, metCfgs, summary)
+
+	return summary
+}
+
+// runSource collects all metrics of a single source
+func (r *Runner) runSource(ctx context.Context, sourceName string, metCfgs map[string]config.MetricConfig, summary *RunSummary) {
+	start := time.Now()
+	c, err := r.newCollector(sourceName, r.cfg)
+	if err != nil {
+		for metName := range metCfgs {
+			r.finish(summary, MetricResult{Metric: metricName(sourceName, metName), Status: StatusSkipped, Err: err}, start)
+		}
+		return
+	}
+	logrus.Info(fmt.Sprintf("collecting metrics for %q", sourceName))
+
+	// Bound the time spent on a single source
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Collector.TimeoutFor(sourceName))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for metName, metCfg := range metCfgs {
+		wg.Add(1)
+		go func(metName string, metCfg config.MetricConfig) {
+			defer wg.Done()
+			start := time.Now()
+			r.finish(summary, r.runMetric(ctx, c, sourceName, metName, metCfg), start)
+		}(metName, metCfg)
+	}
+	wg.Wait()
+	logrus.Info(fmt.Sprintf("completed collecting metrics for %q", sourceName))
+}
+
+// runMetric collects a single metric and writes every record it can
+func (r *Runner) runMetric(ctx context.Context, c Collector, sourceName string, metName string, metCfg config.MetricConfig) MetricResult {
+	name := metricName(sourceName, metName)
+	res := MetricResult{Metric: name}
+
+	if err := ctx.Err(); err != nil {
+		res.Status = StatusSkipped
+		res.Err = err
+		return res
+	}
+
+	// Collect raw events since the checkpoint and rebuild the metric periods
+	// they fall in from the store
+	g := metCfg.Granularity.OrDefault()
+	start, end := collectionRange(g, metCfg.CollectMonthsBack)
+	fetchStart, err := r.fetchStart(name, start)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = err
+		return res
+	}
+	fetchEnd := time.Now().AddDate(0, 0, -1)
+
+	logrus.Info(fmt.Sprintf("collecting %q since %s", name, fetchStart.Format("2006-01-02")))
+	events, err := c.Collect(ctx, metName, Options{Start: fetchStart, End: fetchEnd})
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to collect metric")
+		return res
+	}
+	logrus.Info(fmt.Sprintf("collected %d %q events", len(events), name))
+
+	stale, changed, err := r.staleEvents(c, sourceName, metName, events)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = err
+		return res
+	}
+
+	if err := r.store.WriteEvents(events); err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to write events")
+		return res
+	}
+
+	if len(stale) > 0 {
+		n, err := r.store.DeleteEvents(stale)
+		if err != nil {
+			res.Status = StatusFailed
+			res.Err = errors.Wrap(err, "failed to delete stale events")
+			return res
+		}
+		logrus.Info(fmt.Sprintf("deleted %d %q events that are no longer returned by %q", n, name, sourceName))
+	}
+
+	metrics, err := Aggregate(r.store, sourceName, metName, g, g.Truncate(fetchStart), end)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = err
+		return res
+	}
+	rebuilt, err := r.aggregateChanged(sourceName, metName, g, changed, g.Truncate(fetchStart))
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = err
+		return res
+	}
+	metrics = append(metrics, rebuilt...)
+
+	res = r.writeMetrics(res, metrics)
+	if res.Status != StatusSucceeded {
+		return res
+	}
+
+	// Only move the checkpoint once everything up to it has been written
+	cp := statboard.Checkpoint{Metric: name, HighWaterMark: fetchEnd, Updated: time.Now()}
+	if err := r.store.WriteCheckpoint(cp); err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to write checkpoint")
+	}
+	return res
+}
+
+// staleEvents compares the events a CompleteCollector returned with the
+// stored events of its complete kinds. It returns the IDs of stored events
+// that were not returned again, and the times of every event that was
+// removed, added or changed, before and after the change.
+func (r *Runner) staleEvents(c Collector, sourceName string, metName string, events []statboard.Event) ([]string, []time.Time, error) {
+	cc, ok := c.(CompleteCollector)
+	if !ok {
+		return nil, nil, nil
+	}
+	kinds := make(map[string]bool)
+	for _, kind := range cc.CompleteKinds(metName) {
+		kinds[kind] = true
+	}
+
+	stored, err := r.store.GetEvents(sourceName, time.Time{}, time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get stored events")
+	}
+	storedByID := make(map[string]statboard.Event)
+	for _, e := range stored {
+		if kinds[e.Kind] {
+			storedByID[e.ID] = e
+		}
+	}
+
+	var changed []time.Time
+	collected := make(map[string]bool)
+	for _, e := range events {
+		collected[e.ID] = true
+		old, ok := storedByID[e.ID]
+		switch {
+		case !ok:
+			changed = append(changed, e.Timestamp)
+		case !old.Timestamp.Equal(e.Timestamp) || old.Value != e.Value || !reflect.DeepEqual(old.Attributes, e.Attributes):
+			changed = append(changed, old.Timestamp, e.Timestamp)
+		}
+	}
+	var stale []string
+	for id, e := range storedByID {
+		if !collected[id] {
+			stale = append(stale, id)
+			changed = append(changed, e.Timestamp)
+		}
+	}
+	sort.Strings(stale)
+	return stale, changed, nil
+}
+
+// aggregateChanged rebuilds the periods before start that contain the given
+// times. Later periods are rebuilt with the rest of the collection.
+func (r *Runner) aggregateChanged(sourceName string, metName string, g statboard.Granularity, times []time.Time, start time.Time) ([]statboard.Metric, error) {
+	seen := make(map[time.Time]bool)
+	var metrics []statboard.Metric
+	for _, t := range times {
+		period := g.Truncate(t)
+		if !period.Before(start) || seen[period] {
+			continue
+		}
+		seen[period] = true
+		rebuilt, err := Aggregate(r.store, sourceName, metName, g, period, period)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, rebuilt...)
+	}
+	return metrics, nil
+}
+
+// fetchStart returns when collection of a metric should start: its checkpoint
+// less the configured overlap, but never before the start of the collection range
+func (r *Runner) fetchStart(name string, rangeStart time.Time) (time.Time, error) {
+	if r.fullRefresh {
+		return rangeStart, nil
+	}
+
+	cp, err := r.store.GetCheckpoint(name)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get checkpoint")
+	}
+	if cp.HighWaterMark.IsZero() {
+		return rangeStart, nil
+	}
+
+	start := cp.HighWaterMark.Add(-r.cfg.Collector.CheckpointOverlap())
+	if start.Before(rangeStart) {
+		return rangeStart, nil
+	}
+	return start, nil
+}
+
+// writeMetrics writes the metrics in a single commit and records the outcome in res
+func (r *Runner) writeMetrics(res MetricResult, metrics []statboard.Metric) MetricResult {
+	if err := r.store.WriteMetrics(metrics); err != nil {
+		res.Status = StatusFailed
+		res.Err = errors.Wrap(err, "failed to write metrics")
+		return res
+	}
+	res.Records = len(metrics)
+	logrus.Info(fmt.Sprintf("wrote %d %q records to database", res.Records, res.Metric))
+
+	res.Status = StatusSucceeded
+	return res
+}
+
+// Reaggregate rebuilds every configured metric from the raw events in the
+// store. Only periods that start after the first stored event of a source are
+// rewritten, so rollups collected before raw events were kept are untouched.
+func (r *Runner) Reaggregate() *RunSummary {
+	summary := &RunSummary{}
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	for _, sourceName := range sortedKeys(r.cfg.Metrics) {
+		events, err := r.store.GetEvents(sourceName, time.Time{}, yesterday.AddDate(0, 0, 1))
+		for metName, metCfg := range r.cfg.Metrics[sourceName] {
+			res := MetricResult{Metric: metricName(sourceName, metName)}
+			switch {
+			case err != nil:
+				res.Status = StatusFailed
+				res.Err = errors.Wrap(err, fmt.Sprintf("failed to get %q events", sourceName))
+			case len(events) == 0:
+				res.Status = StatusSkipped
+			default:
+				g := metCfg.Granularity.OrDefault()
+				metrics, aggErr := Aggregate(r.store, sourceName, metName, g, firstCompletePeriod(g, events[0].Timestamp), g.Truncate(yesterday))
+				if aggErr != nil {
+					res.Status = StatusFailed
+					res.Err = aggErr
+					break
+				}
+				res = r.writeMetrics(res, metrics)
+			}
+			summary.add(res)
+		}
+	}
+
+	return summary
+}
+
+// finish records the result in the run history and adds it to the summary
+func (r *Runner) finish(summary *RunSummary, res MetricResult, start time.Time) {
+	run := statboard.Run{Metric: res.Metric, Start: start, End: time.Now(), Records: res.Records}
+	if res.Err != nil {
+		run.Error = res.Err.Error()
+	}
+	if err := r.store.WriteRun(run); err != nil {
+		logrus.Error(errors.Wrap(err, fmt.Sprintf("failed to write run history for %q", res.Metric)))
+	} else if _, err := r.store.PruneRuns(res.Metric, runHistory); err != nil {
+		logrus.Error(errors.Wrap(err, fmt.Sprintf("failed to prune run history for %q", res.Metric)))
+	}
+	summary.add(res)
+}
+
+// metricName returns the full name of a metric as it is stored
+func metricName(sourceName string, metName string) string {
+	return fmt.Sprintf("%s.%s", sourceName, metName)
+}
@@ -0,0 +1,336 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/ajbosco/statboard/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCollector returns canned events or errors by metric name
+type fakeCollector struct {
+	events map[string][]statboard.Event
+	errs   map[string]error
+
+	mu   sync.Mutex
+	opts map[string]Options
+}
+
+func (c *fakeCollector) Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts == nil {
+		c.opts = make(map[string]Options)
+	}
+	c.opts[metricName] = opts
+
+	return c.events[metricName], c.errs[metricName]
+}
+
+func newTestStore(t *testing.T) (storage.Store, func()) {
+	dir, err := ioutil.TempDir("", "statboard")
+	assert.NoError(t, err)
+
+	s, err := storage.NewStormStore(filepath.Join(dir, "test.db"))
+	assert.NoError(t, err)
+
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// testReadEvent returns a Goodreads read event from yesterday
+func testReadEvent(id string, pages string) statboard.Event {
+	return statboard.Event{
+		ID:         id,
+		Source:     "goodreads",
+		Kind:       "read",
+		Timestamp:  statboard.Daily.Truncate(time.Now().AddDate(0, 0, -1)),
+		Value:      1,
+		Attributes: map[string]string{"pages": pages},
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {}, "pages_read": {}},
+		"github":    {"contributions": {}},
+	}}
+
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		if sourceName == "github" {
+			return nil, errors.New("no credentials")
+		}
+		return &fakeCollector{
+			events: map[string][]statboard.Event{"books_read": {testReadEvent("1", "100")}},
+			errs:   map[string]error{"pages_read": errors.New("api error")},
+		}, nil
+	}
+
+	summary := r.Run(context.Background())
+
+	assert.Equal(t, 1, summary.Count(StatusSucceeded))
+	assert.Equal(t, 1, summary.Count(StatusFailed))
+	assert.Equal(t, 1, summary.Count(StatusSkipped))
+	assert.Error(t, summary.Err())
+
+	// healthy results are persisted despite the other failures
+	metrics, err := s.GetMetric("goodreads.books_read", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 1.0, metrics[0].Value)
+}
+
+func TestRunnerRun_Cancelled(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {}},
+	}}
+
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return &fakeCollector{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary := r.Run(ctx)
+
+	assert.Equal(t, 1, summary.Count(StatusSkipped))
+	assert.Error(t, summary.Err())
+}
+
+func TestRunnerRun_RecordsRuns(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {}, "pages_read": {}},
+	}}
+
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return &fakeCollector{
+			events: map[string][]statboard.Event{"books_read": {testReadEvent("1", "100")}},
+			errs:   map[string]error{"pages_read": errors.New("api error")},
+		}, nil
+	}
+	r.Run(context.Background())
+
+	runs, err := s.GetRuns("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.True(t, runs[0].Succeeded())
+	assert.Equal(t, 1, runs[0].Records)
+	assert.False(t, runs[0].End.Before(runs[0].Start))
+
+	runs, err = s.GetRuns("goodreads.pages_read")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "failed to collect metric: api error", runs[0].Error)
+}
+
+func TestRunnerFinish_CapsRunHistory(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	r := NewRunner(config.Config{}, s)
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < runHistory+5; i++ {
+		r.finish(&RunSummary{}, MetricResult{Metric: "goodreads.books_read", Status: StatusSucceeded}, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	runs, err := s.GetRuns("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.Len(t, runs, runHistory)
+	assert.True(t, runs[0].Start.Equal(start.Add(5*time.Hour)))
+}
+
+// completeFakeCollector is a fakeCollector that always returns every read event
+type completeFakeCollector struct {
+	fakeCollector
+}
+
+func (c *completeFakeCollector) CompleteKinds(metricName string) []string {
+	return []string{"read"}
+}
+
+func TestRunnerRun_PrunesEvents(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	// the read date of book 1 was edited since it was stored
+	moved := testReadEvent("goodreads-1-old", "100")
+	moved.Timestamp = moved.Timestamp.AddDate(0, 0, -1)
+	other := testReadEvent("goodreads-review-1", "")
+	other.Kind = "review"
+	other.Timestamp = moved.Timestamp
+	assert.NoError(t, s.WriteEvents([]statboard.Event{moved, other}))
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {Granularity: statboard.Daily, CollectMonthsBack: 1}},
+	}}
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return &completeFakeCollector{fakeCollector{
+			events: map[string][]statboard.Event{"books_read": {testReadEvent("goodreads-1", "100")}},
+		}}, nil
+	}
+
+	res := r.RunMetric(context.Background(), "goodreads", "books_read")
+	assert.Equal(t, StatusSucceeded, res.Status)
+
+	events, err := s.GetEvents("goodreads", time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "goodreads-review-1", events[0].ID)
+	assert.Equal(t, "goodreads-1", events[1].ID)
+
+	metrics, err := s.GetMetric("goodreads.books_read", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	var total float64
+	for _, m := range metrics {
+		total += m.Value
+	}
+	assert.Equal(t, 1.0, total)
+}
+
+func TestRunnerRun_MovesEditedReadBeforeCheckpoint(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	// book 1 was collected yesterday, and its read date was since edited to
+	// a month before the checkpoint
+	read := testReadEvent("goodreads-1", "100")
+	assert.NoError(t, s.WriteEvents([]statboard.Event{read}))
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "goodreads.books_read", Date: read.Timestamp, Value: 1, Granularity: statboard.Daily}))
+	assert.NoError(t, s.WriteCheckpoint(statboard.Checkpoint{Metric: "goodreads.books_read", HighWaterMark: time.Now().AddDate(0, 0, -1)}))
+	edited := read
+	edited.Timestamp = read.Timestamp.AddDate(0, -1, 0)
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {Granularity: statboard.Daily, CollectMonthsBack: 3}},
+	}}
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return &completeFakeCollector{fakeCollector{
+			events: map[string][]statboard.Event{"books_read": {edited}},
+		}}, nil
+	}
+
+	res := r.RunMetric(context.Background(), "goodreads", "books_read")
+	assert.Equal(t, StatusSucceeded, res.Status)
+
+	events, err := s.GetEvents("goodreads", time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.True(t, edited.Timestamp.Equal(events[0].Timestamp))
+
+	metrics, err := s.GetMetric("goodreads.books_read", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	byDate := make(map[time.Time]float64)
+	var total float64
+	for _, m := range metrics {
+		byDate[m.Date] = m.Value
+		total += m.Value
+	}
+	assert.Equal(t, 1.0, total)
+	assert.Equal(t, 1.0, byDate[edited.Timestamp])
+	assert.Equal(t, 0.0, byDate[read.Timestamp])
+}
+
+func TestRunnerReaggregate(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	err := s.WriteEvents([]statboard.Event{testReadEvent("1", "100"), testReadEvent("2", "250")})
+	assert.NoError(t, err)
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"pages_read": {Granularity: statboard.Daily}},
+		"github":    {"contributions": {}},
+	}}
+
+	summary := NewRunner(cfg, s).Reaggregate()
+
+	assert.Equal(t, 1, summary.Count(StatusSucceeded))
+	assert.Equal(t, 1, summary.Count(StatusSkipped))
+	assert.NoError(t, summary.Err())
+
+	metrics, err := s.GetMetric("goodreads.pages_read", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 350.0, metrics[0].Value)
+}
+
+func TestRunnerReaggregate_LowersStoredValue(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	// a read removed from the raw events was counted before
+	event := testReadEvent("1", "100")
+	assert.NoError(t, s.WriteEvents([]statboard.Event{event}))
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "goodreads.pages_read", Date: event.Timestamp, Value: 350, Granularity: statboard.Daily}))
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"pages_read": {Granularity: statboard.Daily}},
+	}}
+	summary := NewRunner(cfg, s).Reaggregate()
+	assert.NoError(t, summary.Err())
+
+	metrics, err := s.GetMetric("goodreads.pages_read", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 100.0, metrics[0].Value)
+}
+
+func TestRunnerRun_Checkpoint(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {CollectMonthsBack: 12}},
+	}}
+	cfg.Collector.Overlap = 24 * time.Hour
+
+	fc := &fakeCollector{events: map[string][]statboard.Event{"books_read": {testReadEvent("1", "100")}}}
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return fc, nil
+	}
+
+	// the first run collects the full range and sets the checkpoint
+	rangeStart, _ := collectionRange(statboard.Monthly, 12)
+	res := r.RunMetric(context.Background(), "goodreads", "books_read")
+	assert.Equal(t, StatusSucceeded, res.Status)
+	assert.Equal(t, rangeStart, fc.opts["books_read"].Start)
+
+	cp, err := s.GetCheckpoint("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.Equal(t, fc.opts["books_read"].End.Unix(), cp.HighWaterMark.Unix())
+
+	// later runs start at the checkpoint less the overlap
+	r.RunMetric(context.Background(), "goodreads", "books_read")
+	assert.Equal(t, cp.HighWaterMark.Add(-24*time.Hour).Unix(), fc.opts["books_read"].Start.Unix())
+
+	// a full refresh ignores the checkpoint
+	r.SetFullRefresh(true)
+	r.RunMetric(context.Background(), "goodreads", "books_read")
+	assert.Equal(t, rangeStart, fc.opts["books_read"].Start)
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Scheduler runs metric collections on their configured cron schedules.
+// Metrics that share a schedule are collected together on each tick, with a
+// single collector per source. A metric is never collected twice at the same
+// time; a run that is due while the previous one is still going is skipped.
+type Scheduler struct {
+	runner *Runner
+	cron   *cron.Cron
+
+	mu       sync.Mutex
+	ctx      context.Context
+	running  map[string]bool
+	afterMu  sync.Mutex
+	afterRun func()
+}
+
+// scheduledSource is the metrics of a source that share a schedule
+type scheduledSource struct {
+	sourceName string
+	metNames   []string
+}
+
+// NewScheduler creates a Scheduler for every metric configured in the runner
+func NewScheduler(runner *Runner) (*Scheduler, error) {
+	s := &Scheduler{
+		runner:  runner,
+		cron:    cron.New(),
+		ctx:     context.Background(),
+		running: make(map[string]bool),
+	}
+
+	var specs []string
+	bySpec := make(map[string][]scheduledSource)
+	for _, sourceName := range sortedKeys(runner.cfg.Metrics) {
+		metCfgs := runner.cfg.Metrics[sourceName]
+		var metNames []string
+		for metName := range metCfgs {
+			metNames = append(metNames, metName)
+		}
+		sort.Strings(metNames)
+
+		for _, metName := range metNames {
+			metCfg := metCfgs[metName]
+			name := metricName(sourceName, metName)
+			spec := runner.cfg.ScheduleFor(metCfg)
+			if spec == "" {
+				return nil, fmt.Errorf("no schedule for %q, set 'schedule' on the metric or 'collector.schedule'", name)
+			}
+			if _, err := cron.ParseStandard(spec); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("invalid schedule %q for %q", spec, name))
+			}
+
+			sources, ok := bySpec[spec]
+			if !ok {
+				specs = append(specs, spec)
+			}
+			if n := len(sources); n > 0 && sources[n-1].sourceName == sourceName {
+				sources[n-1].metNames = append(sources[n-1].metNames, metName)
+			} else {
+				sources = append(sources, scheduledSource{sourceName: sourceName, metNames: []string{metName}})
+			}
+			bySpec[spec] = sources
+			logrus.Info(fmt.Sprintf("scheduled %q with %q", name, spec))
+		}
+	}
+
+	for _, spec := range specs {
+		sources := bySpec[spec]
+		if _, err := s.cron.AddFunc(spec, func() { s.tick(sources) }); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid schedule %q", spec))
+		}
+	}
+
+	return s, nil
+}
+
+// SetAfterRun sets a function called once per schedule tick, after every
+// metric due on it has been collected. Calls never overlap.
+func (s *Scheduler) SetAfterRun(fn func()) {
+	s.afterRun = fn
+}
+
+// Start begins running collections in the background. Collections use ctx,
+// so cancelling it aborts any in-flight runs.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs and waits for running collections to finish
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// tick collects the sources due on a schedule concurrently, then calls
+// afterRun once if any of their metrics ran
+func (s *Scheduler) tick(sources []scheduledSource) {
+	var wg sync.WaitGroup
+	var ranMu sync.Mutex
+	var ran bool
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src scheduledSource) {
+			defer wg.Done()
+			if s.run(src.sourceName, src.metNames) {
+				ranMu.Lock()
+				ran = true
+				ranMu.Unlock()
+			}
+		}(src)
+	}
+	wg.Wait()
+
+	if ran && s.afterRun != nil {
+		s.afterMu.Lock()
+		defer s.afterMu.Unlock()
+		s.afterRun()
+	}
+}
+
+// run collects the metrics of a source whose previous run is not still in
+// progress with a single collector and reports whether any of them ran
+func (s *Scheduler) run(sourceName string, metNames []string) bool {
+	var due []string
+	for _, metName := range metNames {
+		name := metricName(sourceName, metName)
+		if !s.tryStart(name) {
+			logrus.Warn(fmt.Sprintf("skipping %q, previous run is still in progress", name))
+			continue
+		}
+		defer s.done(name)
+		due = append(due, metName)
+	}
+	if len(due) == 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	summary := s.runner.RunSource(ctx, sourceName, due)
+	for _, res := range summary.Results {
+		entry := logrus.WithFields(logrus.Fields{"metric": res.Metric, "status": res.Status, "records": res.Records})
+		if res.Err != nil {
+			entry.Error(res.Err)
+		} else {
+			entry.Info("scheduled collection finished")
+		}
+	}
+	return true
+}
+
+// tryStart marks a metric as running and reports false if it already was
+func (s *Scheduler) tryStart(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[name] {
+		return false
+	}
+	s.running[name] = true
+	return true
+}
+
+// done marks a metric as no longer running
+func (s *Scheduler) done(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingCollector blocks every Collect call until release is closed
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCollector) Collect(ctx context.Context, metricName string, opts Options) ([]statboard.Event, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return nil, nil
+}
+
+func TestNewScheduler(t *testing.T) {
+	tt := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{
+			name: "metric schedule",
+			cfg: config.Config{Metrics: map[string]map[string]config.MetricConfig{
+				"github": {"contributions": {Schedule: "@every 6h"}},
+			}},
+		},
+		{
+			name: "missing schedule",
+			cfg: config.Config{Metrics: map[string]map[string]config.MetricConfig{
+				"github": {"contributions": {}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "invalid schedule",
+			cfg: config.Config{Metrics: map[string]map[string]config.MetricConfig{
+				"github": {"contributions": {Schedule: "every day"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			_, err := NewScheduler(NewRunner(ts.cfg, nil))
+			if ts.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewScheduler_CollectorSchedule(t *testing.T) {
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"github": {"contributions": {}},
+	}}
+	cfg.Collector.Schedule = "0 3 * * *"
+
+	_, err := NewScheduler(NewRunner(cfg, nil))
+	assert.NoError(t, err)
+}
+
+func TestSchedulerRun_SkipsOverlappingRuns(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {Schedule: "@every 1h"}},
+	}}
+	c := &blockingCollector{started: make(chan struct{}, 2), release: make(chan struct{})}
+
+	r := NewRunner(cfg, s)
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		return c, nil
+	}
+	sched, err := NewScheduler(r)
+	assert.NoError(t, err)
+	var afterRuns int
+	sched.SetAfterRun(func() { afterRuns++ })
+
+	due := []scheduledSource{{sourceName: "goodreads", metNames: []string{"books_read"}}}
+	finished := make(chan struct{})
+	go func() {
+		sched.tick(due)
+		close(finished)
+	}()
+	<-c.started
+
+	// a second run while the first is in progress returns immediately,
+	// without calling afterRun
+	sched.tick(due)
+	assert.Len(t, c.started, 0)
+	assert.Equal(t, 0, afterRuns)
+
+	close(c.release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("scheduled run did not finish")
+	}
+
+	runs, err := s.GetRuns("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, 1, afterRuns)
+}
+
+func TestSchedulerTick_AfterRunOncePerTick(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"goodreads": {"books_read": {}, "pages_read": {}},
+		"fitbit":    {"steps": {}},
+		"github":    {"contributions": {Schedule: "@every 6h"}},
+	}}
+	cfg.Collector.Schedule = "0 3 * * *"
+
+	r := NewRunner(cfg, s)
+	collectors := make(map[string]int)
+	var collectorsMu sync.Mutex
+	r.newCollector = func(sourceName string, cfg config.Config) (Collector, error) {
+		collectorsMu.Lock()
+		defer collectorsMu.Unlock()
+		collectors[sourceName]++
+		return &fakeCollector{}, nil
+	}
+	sched, err := NewScheduler(r)
+	assert.NoError(t, err)
+	var afterRuns int
+	sched.SetAfterRun(func() { afterRuns++ })
+
+	// metrics sharing a schedule are collected by a single cron entry. Until
+	// the scheduler starts, entries are in the order they were added.
+	entries := sched.cron.Entries()
+	assert.Len(t, entries, 2)
+
+	// the daily tick collects its three metrics with one collector per
+	// source and snapshots once
+	entries[0].Job.Run()
+	assert.Equal(t, 1, afterRuns)
+	assert.Equal(t, map[string]int{"fitbit": 1, "goodreads": 1}, collectors)
+	for _, name := range []string{"fitbit.steps", "goodreads.books_read", "goodreads.pages_read"} {
+		runs, err := s.GetRuns(name)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 1, name)
+	}
+	runs, err := s.GetRuns("github.contributions")
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+}
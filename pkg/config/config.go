@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/ajbosco/statboard/pkg/statboard"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -15,6 +17,75 @@ type Config struct {
 	Github    githubConfig                       `mapstructure:"github" yaml:"github"`
 	Goodreads goodreadsConfig                    `mapstructure:"goodreads" yaml:"goodreads"`
 	Metrics   map[string]map[string]MetricConfig `mapstructure:"metrics" yaml:"metrics"`
+	Collector collectorConfig                    `mapstructure:"collector" yaml:"collector,omitempty"`
+	Store     storeConfig                        `mapstructure:"store" yaml:"store,omitempty"`
+	Panels    []PanelConfig                      `mapstructure:"panels" yaml:"panels,omitempty"`
+}
+
+// PanelConfig draws several metrics on one dashboard chart
+type PanelConfig struct {
+	ChartName       string              `mapstructure:"chart_name" yaml:"chart_name"`
+	ChartMonthsBack int                 `mapstructure:"chart_months_back" yaml:"chart_months_back"`
+	Metrics         []PanelMetricConfig `mapstructure:"metrics" yaml:"metrics"`
+}
+
+// PanelMetricConfig is a metric on a panel, named source.metric. Axis is
+// "left", the default, or "right" for a secondary y-axis.
+type PanelMetricConfig struct {
+	Metric string `mapstructure:"metric" yaml:"metric"`
+	Axis   string `mapstructure:"axis" yaml:"axis,omitempty"`
+}
+
+type storeConfig struct {
+	Backend      string `mapstructure:"backend" yaml:"backend,omitempty"`
+	DSN          string `mapstructure:"dsn" yaml:"dsn,omitempty"`
+	WritePolicy  string `mapstructure:"write_policy" yaml:"write_policy,omitempty"`
+	SnapshotDir  string `mapstructure:"snapshot_dir" yaml:"snapshot_dir,omitempty"`
+	SnapshotKeep int    `mapstructure:"snapshot_keep" yaml:"snapshot_keep,omitempty"`
+}
+
+// DefaultSnapshotKeep is used when no number of snapshots to keep is configured
+const DefaultSnapshotKeep = 7
+
+// SnapshotsToKeep returns how many database snapshots are kept in the snapshot dir
+func (c storeConfig) SnapshotsToKeep() int {
+	if c.SnapshotKeep > 0 {
+		return c.SnapshotKeep
+	}
+	return DefaultSnapshotKeep
+}
+
+// DefaultCollectTimeout is used for sources without a configured timeout
+const DefaultCollectTimeout = 5 * time.Minute
+
+type collectorConfig struct {
+	Timeout        time.Duration            `mapstructure:"timeout" yaml:"timeout,omitempty"`
+	SourceTimeouts map[string]time.Duration `mapstructure:"source_timeouts" yaml:"source_timeouts,omitempty"`
+	Schedule       string                   `mapstructure:"schedule" yaml:"schedule,omitempty"`
+	Overlap        time.Duration            `mapstructure:"overlap" yaml:"overlap,omitempty"`
+}
+
+// TimeoutFor returns how long a collection from the given source may take
+func (c collectorConfig) TimeoutFor(source string) time.Duration {
+	if t, ok := c.SourceTimeouts[source]; ok && t > 0 {
+		return t
+	}
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultCollectTimeout
+}
+
+// DefaultCheckpointOverlap is used when no checkpoint overlap is configured
+const DefaultCheckpointOverlap = 72 * time.Hour
+
+// CheckpointOverlap returns how far before its checkpoint an incremental
+// collection starts, so late-arriving edits are picked up
+func (c collectorConfig) CheckpointOverlap() time.Duration {
+	if c.Overlap > 0 {
+		return c.Overlap
+	}
+	return DefaultCheckpointOverlap
 }
 
 type fitbitConfig struct {
@@ -37,10 +108,48 @@ type goodreadsConfig struct {
 
 // MetricConfig contains information for collecting and visualizing a metric
 type MetricConfig struct {
-	ChartName         string `mapstructure:"chart_name" yaml:"chart_name"`
-	ChartColor        string `mapstructure:"chart_color" yaml:"chart_color"`
-	ChartMonthsBack   int    `mapstructure:"chart_months_back" yaml:"chart_months_back"`
-	CollectMonthsBack int    `mapstructure:"collect_months_back" yaml:"collect_months_back"`
+	ChartName         string                `mapstructure:"chart_name" yaml:"chart_name" json:"chart_name,omitempty"`
+	ChartColor        string                `mapstructure:"chart_color" yaml:"chart_color" json:"chart_color,omitempty"`
+	ChartType         string                `mapstructure:"chart_type" yaml:"chart_type,omitempty" json:"chart_type,omitempty"`
+	ChartUnit         string                `mapstructure:"chart_unit" yaml:"chart_unit,omitempty" json:"chart_unit,omitempty"`
+	ChartYMin         *float64              `mapstructure:"chart_y_min" yaml:"chart_y_min,omitempty" json:"chart_y_min,omitempty"`
+	ChartYMax         *float64              `mapstructure:"chart_y_max" yaml:"chart_y_max,omitempty" json:"chart_y_max,omitempty"`
+	ChartShowPoints   *bool                 `mapstructure:"chart_show_points" yaml:"chart_show_points,omitempty" json:"chart_show_points,omitempty"`
+	ChartMonthsBack   int                   `mapstructure:"chart_months_back" yaml:"chart_months_back" json:"chart_months_back,omitempty"`
+	ChartCompareYears int                   `mapstructure:"chart_compare_years" yaml:"chart_compare_years,omitempty" json:"chart_compare_years,omitempty"`
+	CollectMonthsBack int                   `mapstructure:"collect_months_back" yaml:"collect_months_back" json:"collect_months_back,omitempty"`
+	StaleAfter        time.Duration         `mapstructure:"stale_after" yaml:"stale_after,omitempty" json:"stale_after,omitempty"`
+	Schedule          string                `mapstructure:"schedule" yaml:"schedule,omitempty" json:"schedule,omitempty"`
+	Granularity       statboard.Granularity `mapstructure:"granularity" yaml:"granularity,omitempty" json:"granularity,omitempty"`
+	Retention         []RetentionConfig     `mapstructure:"retention" yaml:"retention,omitempty" json:"retention,omitempty"`
+}
+
+// RetentionConfig limits how long rows of one granularity of a metric are
+// kept. Older rows are summed into RollupTo periods, if set, and deleted.
+type RetentionConfig struct {
+	Granularity statboard.Granularity `mapstructure:"granularity" yaml:"granularity" json:"granularity,omitempty"`
+	KeepDays    int                   `mapstructure:"keep_days" yaml:"keep_days" json:"keep_days,omitempty"`
+	RollupTo    statboard.Granularity `mapstructure:"rollup_to" yaml:"rollup_to,omitempty" json:"rollup_to,omitempty"`
+}
+
+// DefaultStaleAfter is used for metrics without a configured staleness threshold
+const DefaultStaleAfter = 48 * time.Hour
+
+// StaleThreshold returns how long after its last successful collection a metric is stale
+func (m MetricConfig) StaleThreshold() time.Duration {
+	if m.StaleAfter > 0 {
+		return m.StaleAfter
+	}
+	return DefaultStaleAfter
+}
+
+// ScheduleFor returns the collection schedule of a metric, falling back to the
+// collector's default schedule
+func (c *Config) ScheduleFor(metCfg MetricConfig) string {
+	if metCfg.Schedule != "" {
+		return metCfg.Schedule
+	}
+	return c.Collector.Schedule
 }
 
 // Write writes a Config object to the config file
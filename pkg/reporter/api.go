@@ -0,0 +1,232 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// apiDateFormat is the format of the from and to query parameters
+const apiDateFormat = "2006-01-02"
+
+// apiMetric is a configured metric as it is listed by the API
+type apiMetric struct {
+	Name        string                `json:"name"`
+	Source      string                `json:"source"`
+	Metric      string                `json:"metric"`
+	Granularity statboard.Granularity `json:"granularity"`
+	Config      config.MetricConfig   `json:"config"`
+}
+
+// apiSeries is the stored values of a metric in a range
+type apiSeries struct {
+	Metric      string                `json:"metric"`
+	Granularity statboard.Granularity `json:"granularity"`
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Points      []apiPoint            `json:"points"`
+}
+
+// apiPoint is the value of a metric for a single period
+type apiPoint struct {
+	Period string    `json:"period"`
+	Date   time.Time `json:"date"`
+	Value  float64   `json:"value"`
+}
+
+// apiError is the body of every failed API request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// handleAPIMetrics lists every configured metric ordered by name
+func (s *Server) handleAPIMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics := []apiMetric{}
+		for sourceName, metCfgs := range s.cfg.Metrics {
+			for metName, metCfg := range metCfgs {
+				metrics = append(metrics, newAPIMetric(sourceName, metName, metCfg))
+			}
+		}
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+		writeJSON(w, http.StatusOK, map[string][]apiMetric{"metrics": metrics})
+	}
+}
+
+// handleAPIMetric returns the config of a single metric
+func (s *Server) handleAPIMetric() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		met, ok := s.lookupMetric(mux.Vars(r)["metric"])
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("metric %q is not configured", mux.Vars(r)["metric"]))
+			return
+		}
+		writeJSON(w, http.StatusOK, met)
+	}
+}
+
+// handleAPISeries returns the values of a metric. The optional from
+// (inclusive) and to (exclusive) dates bound the series and granularity
+// overrides the configured granularity with one it rolls up to.
+func (s *Server) handleAPISeries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		met, ok := s.lookupMetric(mux.Vars(r)["metric"])
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("metric %q is not configured", mux.Vars(r)["metric"]))
+			return
+		}
+
+		query := r.URL.Query()
+		series := apiSeries{Metric: met.Name, Granularity: met.Granularity, To: time.Now().UTC()}
+		if g := query.Get("granularity"); g != "" {
+			granularity, err := statboard.ParseGranularity(g)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if granularity != met.Granularity && !met.Granularity.RollsUpTo(granularity) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("metric %q is stored by %s and cannot be rolled up to %s", met.Name, met.Granularity, granularity))
+				return
+			}
+			series.Granularity = granularity
+		}
+		for param, t := range map[string]*time.Time{"from": &series.From, "to": &series.To} {
+			if v := query.Get(param); v != "" {
+				parsed, err := time.Parse(apiDateFormat, v)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %q date %q, expected YYYY-MM-DD", param, v))
+					return
+				}
+				*t = parsed
+			}
+		}
+		if !series.From.Before(series.To) {
+			writeError(w, http.StatusBadRequest, "'from' must be before 'to'")
+			return
+		}
+		if series.Granularity != met.Granularity {
+			// rolled up periods are always whole, so the range is widened
+			// to the periods its ends fall in
+			series.From = series.Granularity.Truncate(series.From)
+			if to := series.Granularity.Truncate(series.To); !to.Equal(series.To) {
+				series.To = series.Granularity.Next(to)
+			}
+		}
+
+		metrics, err := s.getSeries(met, series.Granularity, series.From, series.To)
+		if err != nil {
+			logrus.Error(err)
+			writeError(w, http.StatusInternalServerError, "failed to get metric")
+			return
+		}
+		series.Points = []apiPoint{}
+		for _, m := range metrics {
+			series.Points = append(series.Points, apiPoint{Period: series.Granularity.Period(m.Date), Date: m.Date, Value: m.Value})
+		}
+
+		writeJSON(w, http.StatusOK, series)
+	}
+}
+
+// getSeries returns the values of a metric by g in the range ordered by date.
+// A coarser g is summed from the stored rows, except for the periods that
+// retention has already rolled up to g. Its range must cover whole periods.
+func (s *Server) getSeries(met apiMetric, g statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error) {
+	if g == met.Granularity {
+		return s.store.GetMetricRange(met.Name, g, from, to)
+	}
+
+	stored, err := s.store.GetMetricRange(met.Name, met.Granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+	rolledUp, err := s.store.GetMetricRange(met.Name, g, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[time.Time]float64)
+	for _, m := range stored {
+		sums[g.Truncate(m.Date)] += m.Value
+	}
+	for _, m := range rolledUp {
+		sums[g.Truncate(m.Date)] = m.Value
+	}
+
+	metrics := []statboard.Metric{}
+	for date, value := range sums {
+		metrics = append(metrics, statboard.Metric{Name: met.Name, Date: date, Value: value, Granularity: g})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Date.Before(metrics[j].Date) })
+	return metrics, nil
+}
+
+// handleAPINotFound answers requests to unknown API routes
+func (s *Server) handleAPINotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no API route for %s", r.URL.Path))
+	}
+}
+
+// apiGet rejects requests to a read-only API route that are not GETs. Routes
+// do not use mux's method matching, which answers mismatches on subrouters
+// with a plain text 404.
+func apiGet(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %s", r.Method, r.URL.Path))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// lookupMetric returns the configured metric with the full name source.metric
+func (s *Server) lookupMetric(name string) (apiMetric, bool) {
+	return findMetric(s.cfg.Metrics, name)
+}
+
+// findMetric returns the metric with the full name source.metric from the metrics config
+func findMetric(metrics map[string]map[string]config.MetricConfig, name string) (apiMetric, bool) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return apiMetric{}, false
+	}
+	metCfg, ok := metrics[parts[0]][parts[1]]
+	if !ok {
+		return apiMetric{}, false
+	}
+	return newAPIMetric(parts[0], parts[1], metCfg), true
+}
+
+func newAPIMetric(sourceName string, metName string, metCfg config.MetricConfig) apiMetric {
+	return apiMetric{
+		Name:        fmt.Sprintf("%s.%s", sourceName, metName),
+		Source:      sourceName,
+		Metric:      metName,
+		Granularity: metCfg.Granularity.OrDefault(),
+		Config:      metCfg,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiError{Error: msg})
+}
@@ -0,0 +1,254 @@
+package reporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/ajbosco/statboard/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) (*Server, func()) {
+	dir, err := ioutil.TempDir("", "statboard")
+	assert.NoError(t, err)
+
+	s, err := storage.NewStormStore(filepath.Join(dir, "test.db"))
+	assert.NoError(t, err)
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.WriteMetrics([]statboard.Metric{
+		{Name: "github.contributions", Date: testTime, Value: 5.0},
+		{Name: "github.contributions", Date: testTime.AddDate(0, 1, 0), Value: 7.0},
+		{Name: "github.contributions", Date: testTime.AddDate(0, 2, 0), Value: 9.0},
+		{Name: "github.contributions", Date: testTime, Value: 1.0, Granularity: statboard.Daily},
+	}))
+
+	cfg := config.Config{Metrics: map[string]map[string]config.MetricConfig{
+		"github":    {"contributions": {ChartName: "Github Contributions", ChartMonthsBack: 6}},
+		"goodreads": {"books_read": {ChartName: "Books Read", Granularity: statboard.Weekly}},
+	}}
+	srv := NewServer(cfg, "", s)
+	srv.apiRoutes()
+
+	return srv, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func serveAPI(srv *Server, target string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestAPIMetrics(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	w, _ := serveAPI(srv, "/api/v1/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body struct {
+		Metrics []apiMetric `json:"metrics"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Metrics, 2)
+	assert.Equal(t, "github.contributions", body.Metrics[0].Name)
+	assert.Equal(t, statboard.Monthly, body.Metrics[0].Granularity)
+	assert.Equal(t, "Github Contributions", body.Metrics[0].Config.ChartName)
+	assert.Equal(t, "goodreads", body.Metrics[1].Source)
+	assert.Equal(t, "books_read", body.Metrics[1].Metric)
+	assert.Equal(t, statboard.Weekly, body.Metrics[1].Granularity)
+}
+
+func TestAPIMetric(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	w, body := serveAPI(srv, "/api/v1/metrics/goodreads.books_read")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "goodreads.books_read", body["name"])
+
+	w, body = serveAPI(srv, "/api/v1/metrics/github.stars")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `metric "github.stars" is not configured`, body["error"])
+}
+
+func TestAPISeries(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// 2017 was rolled up to a yearly row by retention
+	assert.NoError(t, srv.store.WriteMetric(statboard.Metric{Name: "github.contributions", Date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), Value: 40.0, Granularity: statboard.Yearly}))
+
+	tt := []struct {
+		name     string
+		target   string
+		code     int
+		expected []apiPoint
+		err      string
+	}{
+		{
+			name:   "all values",
+			target: "/api/v1/metrics/github.contributions/series",
+			code:   http.StatusOK,
+			expected: []apiPoint{
+				{Period: "2018-01", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 5.0},
+				{Period: "2018-02", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Value: 7.0},
+				{Period: "2018-03", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 9.0},
+			},
+		},
+		{
+			name:   "range",
+			target: "/api/v1/metrics/github.contributions/series?from=2018-02-01&to=2018-03-01",
+			code:   http.StatusOK,
+			expected: []apiPoint{
+				{Period: "2018-02", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Value: 7.0},
+			},
+		},
+		{
+			name:   "granularity",
+			target: "/api/v1/metrics/github.contributions/series?granularity=year",
+			code:   http.StatusOK,
+			expected: []apiPoint{
+				{Period: "2017", Date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), Value: 40.0},
+				{Period: "2018", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 21.0},
+			},
+		},
+		{
+			name:   "granularity range",
+			target: "/api/v1/metrics/github.contributions/series?granularity=year&from=2018-02-01",
+			code:   http.StatusOK,
+			expected: []apiPoint{
+				{Period: "2018", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 21.0},
+			},
+		},
+		{
+			name:     "no values",
+			target:   "/api/v1/metrics/goodreads.books_read/series",
+			code:     http.StatusOK,
+			expected: []apiPoint{},
+		},
+		{
+			name:   "invalid date",
+			target: "/api/v1/metrics/github.contributions/series?from=January",
+			code:   http.StatusBadRequest,
+			err:    `invalid "from" date "January", expected YYYY-MM-DD`,
+		},
+		{
+			name:   "empty range",
+			target: "/api/v1/metrics/github.contributions/series?from=2018-03-01&to=2018-02-01",
+			code:   http.StatusBadRequest,
+			err:    "'from' must be before 'to'",
+		},
+		{
+			name:   "invalid granularity",
+			target: "/api/v1/metrics/github.contributions/series?granularity=hour",
+			code:   http.StatusBadRequest,
+			err:    `unsupported granularity "hour", valid granularities are: day, week, month, year`,
+		},
+		{
+			name:   "finer granularity",
+			target: "/api/v1/metrics/github.contributions/series?granularity=week",
+			code:   http.StatusBadRequest,
+			err:    `metric "github.contributions" is stored by month and cannot be rolled up to week`,
+		},
+		{
+			name:   "unknown metric",
+			target: "/api/v1/metrics/contributions/series",
+			code:   http.StatusNotFound,
+			err:    `metric "contributions" is not configured`,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			w, body := serveAPI(srv, ts.target)
+			assert.Equal(t, ts.code, w.Code)
+			if ts.err != "" {
+				assert.Equal(t, ts.err, body["error"])
+				return
+			}
+
+			var series apiSeries
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+			assert.Equal(t, ts.expected, series.Points)
+		})
+	}
+}
+
+func TestAPISeries_WholePeriods(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	srv.cfg.Metrics["fitbit"] = map[string]config.MetricConfig{"steps": {Granularity: statboard.Daily}}
+	var steps []statboard.Metric
+	for d := time.Date(2018, 3, 5, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2018, 3, 26, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		steps = append(steps, statboard.Metric{Name: "fitbit.steps", Date: d, Value: 10.0, Granularity: statboard.Daily})
+	}
+	assert.NoError(t, srv.store.WriteMetrics(steps))
+
+	week11 := apiPoint{Period: "2018-W11", Date: time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), Value: 70.0}
+	tt := []struct {
+		name     string
+		target   string
+		expected []apiPoint
+		from     time.Time
+		to       time.Time
+	}{
+		{
+			name:     "partial week",
+			target:   "/api/v1/metrics/fitbit.steps/series?granularity=week&from=2018-03-13&to=2018-03-14",
+			expected: []apiPoint{week11},
+			from:     time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "whole week",
+			target:   "/api/v1/metrics/fitbit.steps/series?granularity=week&from=2018-03-12&to=2018-03-19",
+			expected: []apiPoint{week11},
+			from:     time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			w, _ := serveAPI(srv, ts.target)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var series apiSeries
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+			assert.Equal(t, ts.expected, series.Points)
+			assert.Equal(t, ts.from, series.From)
+			assert.Equal(t, ts.to, series.To)
+		})
+	}
+}
+
+func TestAPINotFound(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	w, body := serveAPI(srv, "/api/v1/charts")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "no API route for /api/v1/charts", body["error"])
+
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Contains(t, w.Body.String(), `"error"`)
+}
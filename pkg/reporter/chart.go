@@ -3,41 +3,152 @@ package reporter
 import (
 	"fmt"
 	"html/template"
+	"sort"
 	"strings"
 
 	chartjs "github.com/ajbosco/goChartjs"
+	"github.com/ajbosco/statboard/pkg/config"
 	"github.com/ajbosco/statboard/pkg/statboard"
 	"github.com/pkg/errors"
 	colors "gopkg.in/go-playground/colors.v1"
 )
 
+const (
+	// chartArea is a line chart filled down to zero, the default chart type
+	chartArea = "area"
+	// chartLine is a line chart without fill
+	chartLine = "line"
+	// chartBar is a bar chart
+	chartBar = "bar"
+	// chartCumulative is a line chart of the running total
+	chartCumulative = "cumulative"
+	// chartHeatmap is a calendar heatmap of daily values rendered as SVG
+	chartHeatmap = "heatmap"
+)
+
+var chartTypes = []string{chartArea, chartLine, chartBar, chartCumulative, chartHeatmap}
+
 // chart contains information for creating a new metric chart
 type chart struct {
 	metricName string
+	Metric     string
 	ChartName  string
+	chartType  string
 	color      string
+	timeUnit   string
+	unit       string
+	yMin       *float64
+	yMax       *float64
+	showPoints *bool
 	metrics    []statboard.Metric
+	years      []comparisonYear
 	ChartJS    template.HTML
+	SVG        template.HTML
 }
 
-// newChart returns a chart object
-func newChart(metricName string, chartName string, color string, metrics []statboard.Metric) (chart, error) {
+// newChart returns a chart object for the metric and its chart config
+func newChart(metricName string, metCfg config.MetricConfig, metrics []statboard.Metric) (chart, error) {
+	if err := validateChart(metricName, metCfg); err != nil {
+		return chart{}, err
+	}
 	validName := strings.Replace(metricName, ".", "_", -1)
-	hex, err := colors.ParseHEX(color)
+	hex, err := colors.ParseHEX(metCfg.ChartColor)
 	if err != nil {
-		return chart{}, errors.Wrap(err, fmt.Sprintf("failed to parse color %q", color))
+		return chart{}, errors.Wrap(err, fmt.Sprintf("failed to parse color %q", metCfg.ChartColor))
+	}
+
+	return chart{
+		metricName: validName,
+		Metric:     metricName,
+		ChartName:  metCfg.ChartName,
+		chartType:  chartTypeOrDefault(metCfg.ChartType),
+		color:      hex.ToRGB().String(),
+		timeUnit:   string(metCfg.Granularity.OrDefault()),
+		unit:       metCfg.ChartUnit,
+		yMin:       metCfg.ChartYMin,
+		yMax:       metCfg.ChartYMax,
+		showPoints: metCfg.ChartShowPoints,
+		metrics:    metrics,
+	}, nil
+}
+
+// ValidateCharts checks the chart config of every metric and panel
+func ValidateCharts(cfg config.Config) error {
+	var problems []string
+	for sourceName, metCfgs := range cfg.Metrics {
+		for metName, metCfg := range metCfgs {
+			if err := validateChart(fmt.Sprintf("%s.%s", sourceName, metName), metCfg); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	for _, panelCfg := range cfg.Panels {
+		if err := validatePanel(cfg.Metrics, panelCfg); err != nil {
+			problems = append(problems, err.Error())
+		}
 	}
 
-	return chart{metricName: validName, ChartName: chartName, color: hex.ToRGB().String(), metrics: metrics}, nil
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateChart checks that the chart type and options of a metric can be drawn together
+func validateChart(metricName string, metCfg config.MetricConfig) error {
+	chartType := chartTypeOrDefault(metCfg.ChartType)
+	switch chartType {
+	case chartArea, chartLine, chartCumulative:
+	case chartBar:
+		if metCfg.ChartShowPoints != nil {
+			return fmt.Errorf("metric %q: 'chart_show_points' is not supported for bar charts", metricName)
+		}
+	case chartHeatmap:
+		if metCfg.Granularity.OrDefault() != statboard.Daily {
+			return fmt.Errorf("metric %q: chart type %q needs daily data, set 'granularity: day'", metricName, chartType)
+		}
+		if metCfg.ChartYMin != nil || metCfg.ChartYMax != nil || metCfg.ChartShowPoints != nil {
+			return fmt.Errorf("metric %q: 'chart_y_min', 'chart_y_max' and 'chart_show_points' are not supported for heatmaps", metricName)
+		}
+	default:
+		return fmt.Errorf("metric %q: unsupported chart type %q, valid chart types are: %s", metricName, metCfg.ChartType, strings.Join(chartTypes, ", "))
+	}
+
+	if metCfg.ChartCompareYears < 0 {
+		return fmt.Errorf("metric %q: 'chart_compare_years' must not be negative", metricName)
+	}
+	if metCfg.ChartCompareYears > 0 && (chartType == chartHeatmap || metCfg.Granularity.OrDefault() != statboard.Monthly) {
+		return fmt.Errorf("metric %q: 'chart_compare_years' is only supported for monthly metrics", metricName)
+	}
+
+	if metCfg.ChartYMin != nil && metCfg.ChartYMax != nil && *metCfg.ChartYMin >= *metCfg.ChartYMax {
+		return fmt.Errorf("metric %q: 'chart_y_min' must be less than 'chart_y_max'", metricName)
+	}
+	return nil
+}
+
+// chartTypeOrDefault returns the area chart type for an unset chart type
+func chartTypeOrDefault(chartType string) string {
+	if chartType == "" {
+		return chartArea
+	}
+	return chartType
 }
 
 // renderChart formats the Statboard metrics and returns chart.js string
 func (c *chart) renderChart() (string, error) {
 	chartData := metricsToPoints(c.metrics)
+	if c.chartType == chartCumulative {
+		chartData = cumulativePoints(chartData)
+	}
 
-	chart := getChart(chartData, c.metricName, c.color)
+	chart, ext := c.getChart(chartData)
+	if len(c.years) > 0 {
+		chart, ext = c.getComparisonChart()
+	}
 
-	s, err := chart.Render()
+	s, err := renderChartJS(chart, ext)
 	if err != nil {
 		return "", errors.Wrap(err, "rendering chart failed")
 	}
@@ -57,21 +168,37 @@ func metricsToPoints(metrics []statboard.Metric) []chartjs.Point {
 	return data
 }
 
-// getChart formats and returns Chartjs object
-func getChart(chartData []chartjs.Point, metricName string, chartColor string) chartjs.Chart {
+// cumulativePoints returns the running total of the points
+func cumulativePoints(points []chartjs.Point) []chartjs.Point {
+	var total float64
+	cumulative := make([]chartjs.Point, len(points))
+	for i, p := range points {
+		total += p.Y
+		cumulative[i] = chartjs.Point{X: p.X, Y: total}
+	}
+	return cumulative
+}
+
+// getChart formats and returns Chartjs object and the options goChartjs has no fields for
+func (c *chart) getChart(chartData []chartjs.Point) (chartjs.Chart, chartjsExtensions) {
 	lineTension := 0
 
 	dataset := []chartjs.Dataset{{
-		Label:           metricName,
+		Label:           c.metricName,
 		LineTension:     &lineTension,
 		Data:            chartData,
-		BackgroundColor: chartColor,
+		BackgroundColor: c.color,
 	},
 	}
 
-	c := chartjs.Chart{
-		Name:      metricName,
-		ChartType: "line",
+	chartType := chartLine
+	if c.chartType == chartBar {
+		chartType = chartBar
+	}
+
+	chart := chartjs.Chart{
+		Name:      c.metricName,
+		ChartType: chartType,
 		Options: &chartjs.Options{
 
 			Scales: chartjs.Scales{
@@ -85,7 +212,7 @@ func getChart(chartData []chartjs.Point, metricName string, chartColor string) c
 				XAxes: []chartjs.Axes{
 					{
 						Time: &chartjs.Time{
-							Unit: "month",
+							Unit: c.timeUnit,
 						},
 						Type: "time",
 					},
@@ -96,7 +223,30 @@ func getChart(chartData []chartjs.Point, metricName string, chartColor string) c
 		},
 	}
 
-	c.Data.Datasets = dataset
+	chart.Data.Datasets = dataset
+
+	ext := chartjsExtensions{Datasets: []jsonObject{{}}, YAxes: []jsonObject{{}}}
+	switch c.chartType {
+	case chartLine, chartCumulative:
+		ext.Datasets[0]["fill"] = false
+		ext.Datasets[0]["borderColor"] = c.color
+	}
+	if c.showPoints != nil && !*c.showPoints {
+		ext.Datasets[0]["pointRadius"] = 0
+	}
+	if c.unit != "" {
+		ext.YAxes[0]["scaleLabel"] = jsonObject{"display": true, "labelString": c.unit}
+	}
+	ticks := jsonObject{}
+	if c.yMin != nil {
+		ticks["min"] = *c.yMin
+	}
+	if c.yMax != nil {
+		ticks["max"] = *c.yMax
+	}
+	if len(ticks) > 0 {
+		ext.YAxes[0]["ticks"] = ticks
+	}
 
-	return c
+	return chart, ext
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	chartjs "github.com/ajbosco/goChartjs"
+	"github.com/ajbosco/statboard/pkg/config"
 	"github.com/ajbosco/statboard/pkg/statboard"
 	"github.com/stretchr/testify/assert"
 )
@@ -89,9 +90,11 @@ func TestChartGetChart(t *testing.T) {
 
 	expected.Data.Datasets = testDataset
 
-	actual := getChart(testPoints, "testMetric", "testColor")
+	c := chart{metricName: "testMetric", chartType: chartArea, color: "testColor", timeUnit: "month"}
+	actual, ext := c.getChart(testPoints)
 
 	assert.Equal(t, expected, actual)
+	assert.Equal(t, chartjsExtensions{Datasets: []jsonObject{{}}, YAxes: []jsonObject{{}}}, ext)
 }
 
 func TestChartNewChart(t *testing.T) {
@@ -106,12 +109,15 @@ func TestChartNewChart(t *testing.T) {
 
 	expected := chart{
 		metricName: "testMetric",
+		Metric:     "testMetric",
 		ChartName:  "testChart",
+		chartType:  "area",
 		color:      "rgb(66,134,244)",
+		timeUnit:   "month",
 		metrics:    testMetric,
 	}
 
-	actual, err := newChart("testMetric", "testChart", "#4286f4", testMetric)
+	actual, err := newChart("testMetric", config.MetricConfig{ChartName: "testChart", ChartColor: "#4286f4"}, testMetric)
 	assert.NoError(t, err)
 
 	assert.Equal(t, expected, actual)
@@ -127,6 +133,106 @@ func TestChartNewChart_InvalidColor(t *testing.T) {
 		},
 	}
 
-	_, err := newChart("testMetric", "testChart", "fakeColor", testMetric)
+	_, err := newChart("testMetric", config.MetricConfig{ChartName: "testChart", ChartColor: "fakeColor"}, testMetric)
 	assert.Error(t, err)
 }
+
+func TestChartValidateCharts(t *testing.T) {
+	zero, ten := 0.0, 10.0
+	hide := false
+
+	tt := []struct {
+		name     string
+		metCfg   config.MetricConfig
+		expected string
+	}{
+		{name: "default", metCfg: config.MetricConfig{}},
+		{name: "line with options", metCfg: config.MetricConfig{ChartType: "line", ChartUnit: "steps", ChartYMin: &zero, ChartYMax: &ten, ChartShowPoints: &hide}},
+		{name: "daily heatmap", metCfg: config.MetricConfig{ChartType: "heatmap", Granularity: statboard.Daily}},
+		{
+			name:     "unsupported type",
+			metCfg:   config.MetricConfig{ChartType: "pie"},
+			expected: `metric "fitbit.steps": unsupported chart type "pie", valid chart types are: area, line, bar, cumulative, heatmap`,
+		},
+		{
+			name:     "monthly heatmap",
+			metCfg:   config.MetricConfig{ChartType: "heatmap"},
+			expected: `metric "fitbit.steps": chart type "heatmap" needs daily data, set 'granularity: day'`,
+		},
+		{
+			name:     "heatmap with y axis",
+			metCfg:   config.MetricConfig{ChartType: "heatmap", Granularity: statboard.Daily, ChartYMax: &ten},
+			expected: `metric "fitbit.steps": 'chart_y_min', 'chart_y_max' and 'chart_show_points' are not supported for heatmaps`,
+		},
+		{
+			name:     "bar with points",
+			metCfg:   config.MetricConfig{ChartType: "bar", ChartShowPoints: &hide},
+			expected: `metric "fitbit.steps": 'chart_show_points' is not supported for bar charts`,
+		},
+		{name: "monthly comparison", metCfg: config.MetricConfig{ChartType: "cumulative", ChartCompareYears: 2}},
+		{
+			name:     "weekly comparison",
+			metCfg:   config.MetricConfig{ChartCompareYears: 1, Granularity: statboard.Weekly},
+			expected: `metric "fitbit.steps": 'chart_compare_years' is only supported for monthly metrics`,
+		},
+		{
+			name:     "negative comparison",
+			metCfg:   config.MetricConfig{ChartCompareYears: -1},
+			expected: `metric "fitbit.steps": 'chart_compare_years' must not be negative`,
+		},
+		{
+			name:     "empty y range",
+			metCfg:   config.MetricConfig{ChartYMin: &ten, ChartYMax: &zero},
+			expected: `metric "fitbit.steps": 'chart_y_min' must be less than 'chart_y_max'`,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			err := ValidateCharts(config.Config{Metrics: map[string]map[string]config.MetricConfig{"fitbit": {"steps": ts.metCfg}}})
+			if ts.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, ts.expected)
+		})
+	}
+}
+
+func TestChartCumulativePoints(t *testing.T) {
+	points := []chartjs.Point{{X: "01-Jan-2018", Y: 1}, {X: "01-Feb-2018", Y: 2}, {X: "01-Mar-2018", Y: 3}}
+	expected := []chartjs.Point{{X: "01-Jan-2018", Y: 1}, {X: "01-Feb-2018", Y: 3}, {X: "01-Mar-2018", Y: 6}}
+	assert.Equal(t, expected, cumulativePoints(points))
+}
+
+func TestChartRenderChart_Options(t *testing.T) {
+	zero, ten := 0.0, 10.0
+	hide := false
+	metrics := []statboard.Metric{{Name: "fitbit.steps", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 4.0}}
+
+	c, err := newChart("fitbit.steps", config.MetricConfig{ChartColor: "#4286f4", ChartType: "line", ChartUnit: "steps", ChartYMin: &zero, ChartYMax: &ten, ChartShowPoints: &hide}, metrics)
+	assert.NoError(t, err)
+	s, err := c.renderChart()
+	assert.NoError(t, err)
+
+	for _, expected := range []string{
+		`"type": "line"`,
+		`"fill": false`,
+		`"pointRadius": 0`,
+		`"labelString": "steps"`,
+		`"min": 0`,
+		`"max": 10`,
+		// existing options are kept when extensions are merged
+		`"beginAtZero": true`,
+		`var fitbit_steps = new Chart(ctx`,
+	} {
+		assert.Contains(t, s, expected)
+	}
+
+	c, err = newChart("fitbit.steps", config.MetricConfig{ChartColor: "#4286f4", ChartType: "bar"}, metrics)
+	assert.NoError(t, err)
+	s, err = c.renderChart()
+	assert.NoError(t, err)
+	assert.Contains(t, s, `"type": "bar"`)
+	assert.NotContains(t, s, `"fill"`)
+}
@@ -0,0 +1,93 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+
+	chartjs "github.com/ajbosco/goChartjs"
+)
+
+// jsonObject is a chart.js config object
+type jsonObject map[string]interface{}
+
+// chartjsExtensions are chart.js settings goChartjs has no fields for. They
+// are merged into the rendered config of the dataset or axis at the same index.
+type chartjsExtensions struct {
+	Datasets []jsonObject
+	XAxes    []jsonObject
+	YAxes    []jsonObject
+}
+
+// chartjsTmpl matches the markup goChartjs renders
+var chartjsTmpl = template.Must(template.New("chart.html").Parse(`
+    <canvas id="{{.Name}}" width="400" height="400"></canvas>
+    <script>
+    var ctx = document.getElementById("{{.Name}}");
+    var {{.Name}} = new Chart(ctx, {{.ChartInfo}})
+    </script>`))
+
+// renderChartJS renders the chart like chartjs.Chart.Render with the extensions merged in
+func renderChartJS(c chartjs.Chart, ext chartjsExtensions) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	var info jsonObject
+	if err := json.Unmarshal(b, &info); err != nil {
+		return "", err
+	}
+
+	if data, ok := info["data"].(map[string]interface{}); ok {
+		mergeList(data["datasets"], ext.Datasets)
+	}
+	if options, ok := info["options"].(map[string]interface{}); ok {
+		if scales, ok := options["scales"].(map[string]interface{}); ok {
+			mergeList(scales["xAxes"], ext.XAxes)
+			mergeList(scales["yAxes"], ext.YAxes)
+		}
+	}
+
+	b, err = json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = chartjsTmpl.Execute(&buf, struct {
+		Name      template.JS
+		ChartInfo template.JS
+	}{
+		template.JS(c.Name),
+		template.JS(b),
+	})
+	return buf.String(), err
+}
+
+// mergeList merges every extension into the object at the same index of list
+func mergeList(list interface{}, ext []jsonObject) {
+	objects, _ := list.([]interface{})
+	for i := range ext {
+		if i >= len(objects) {
+			return
+		}
+		if obj, ok := objects[i].(map[string]interface{}); ok {
+			merge(obj, ext[i])
+		}
+	}
+}
+
+// merge copies src into dst, merging nested objects
+func merge(dst map[string]interface{}, src jsonObject) {
+	for k, v := range src {
+		if nested, ok := v.(jsonObject); ok {
+			if existing, ok := dst[k].(map[string]interface{}); ok {
+				merge(existing, nested)
+				continue
+			}
+			dst[k] = map[string]interface{}(nested)
+			continue
+		}
+		dst[k] = v
+	}
+}
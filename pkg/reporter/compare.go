@@ -0,0 +1,116 @@
+package reporter
+
+import (
+	"strconv"
+	"time"
+
+	chartjs "github.com/ajbosco/goChartjs"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+	colors "gopkg.in/go-playground/colors.v1"
+)
+
+// comparisonYear holds the monthly metrics of one calendar year of a comparison chart
+type comparisonYear struct {
+	year    int
+	metrics []statboard.Metric
+}
+
+// getComparison fetches the monthly metrics of the current calendar year and
+// of the compareYears years before it, oldest first
+func (s *Server) getComparison(metricName string, compareYears int, now time.Time) ([]comparisonYear, error) {
+	var years []comparisonYear
+	for year := now.Year() - compareYears; year <= now.Year(); year++ {
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		metrics, err := s.store.GetMetricRange(metricName, statboard.Monthly, from, from.AddDate(1, 0, 0))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get metric")
+		}
+		years = append(years, comparisonYear{year: year, metrics: metrics})
+	}
+	return years, nil
+}
+
+// comparisonEmpty reports whether no year of a comparison has metrics
+func comparisonEmpty(years []comparisonYear) bool {
+	for _, y := range years {
+		if len(y.metrics) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// getComparisonChart draws every year of the chart as a dataset on a shared
+// January to December axis. The current year is drawn in the chart color on
+// top, earlier years fade out the further back they are.
+func (c *chart) getComparisonChart() (chartjs.Chart, chartjsExtensions) {
+	chart, ext := c.getChart(nil)
+	current := c.years[len(c.years)-1].year
+	lineTension := 0
+
+	var datasets []chartjs.Dataset
+	var datasetExts []jsonObject
+	// chart.js draws the first dataset on top, so start with the current year
+	for i := len(c.years) - 1; i >= 0; i-- {
+		y := c.years[i]
+		chartData := monthOfYearPoints(y.metrics, current)
+		if c.chartType == chartCumulative {
+			chartData = cumulativePoints(chartData)
+		}
+
+		datasetExt := jsonObject{}
+		for k, v := range ext.Datasets[0] {
+			datasetExt[k] = v
+		}
+		color := c.color
+		if yearsBack := current - y.year; yearsBack > 0 {
+			color = fadeColor(c.color, 0.5/float64(yearsBack))
+			// only the current year is filled so it stays readable
+			datasetExt["fill"] = false
+		}
+		datasetExt["borderColor"] = color
+
+		datasets = append(datasets, chartjs.Dataset{
+			Label:           strconv.Itoa(y.year),
+			LineTension:     &lineTension,
+			Data:            chartData,
+			BackgroundColor: color,
+		})
+		datasetExts = append(datasetExts, datasetExt)
+	}
+	chart.Data.Datasets = datasets
+	ext.Datasets = datasetExts
+
+	chart.Options.Scales.XAxes[0].Time.Unit = string(statboard.Monthly)
+	ext.XAxes = []jsonObject{{"time": jsonObject{
+		"min":            time.Date(current, time.January, 1, 0, 0, 0, 0, time.UTC).Format("02-Jan-2006"),
+		"max":            time.Date(current, time.December, 1, 0, 0, 0, 0, time.UTC).Format("02-Jan-2006"),
+		"displayFormats": jsonObject{"month": "MMM"},
+		"tooltipFormat":  "MMMM",
+	}}}
+
+	return chart, ext
+}
+
+// monthOfYearPoints converts monthly metrics to chart.js points moved into
+// year, so that every year lines up on the same months
+func monthOfYearPoints(metrics []statboard.Metric, year int) []chartjs.Point {
+	var data []chartjs.Point
+	for _, met := range metrics {
+		date := time.Date(year, met.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		data = append(data, chartjs.Point{X: date.Format("02-Jan-2006"), Y: met.Value})
+	}
+	return data
+}
+
+// fadeColor returns the rgb color with the given opacity
+func fadeColor(color string, opacity float64) string {
+	rgb, err := colors.ParseRGB(color)
+	if err != nil {
+		return color
+	}
+	rgba := rgb.ToRGBA()
+	rgba.A = opacity
+	return rgba.String()
+}
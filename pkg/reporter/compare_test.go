@@ -0,0 +1,86 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	chartjs "github.com/ajbosco/goChartjs"
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareGetComparison(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	years, err := srv.getComparison("github.contributions", 1, time.Date(2019, 6, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, years, 2)
+	assert.Equal(t, 2018, years[0].year)
+	assert.Len(t, years[0].metrics, 3)
+	assert.Equal(t, 2019, years[1].year)
+	assert.Len(t, years[1].metrics, 0)
+}
+
+func TestCompareGetChartJs_OnlyEarlierYears(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// the last 6 months are empty, but 2018 is within the compared years
+	srv.cfg.Metrics = map[string]map[string]config.MetricConfig{
+		"github": {"contributions": {ChartName: "Github Contributions", ChartColor: "#34675C", ChartMonthsBack: 6, ChartCompareYears: time.Now().Year() - 2018}},
+	}
+	charts, err := srv.getChartJs()
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+	assert.Contains(t, string(charts[0].ChartJS), `"label": "2018"`)
+
+	// without a comparison the empty chart is skipped
+	srv.cfg.Metrics["github"]["contributions"] = config.MetricConfig{ChartName: "Github Contributions", ChartColor: "#34675C", ChartMonthsBack: 6}
+	charts, err = srv.getChartJs()
+	assert.NoError(t, err)
+	assert.Empty(t, charts)
+}
+
+func TestCompareGetComparisonChart(t *testing.T) {
+	c, err := newChart("goodreads.pages_read", config.MetricConfig{ChartColor: "#34675C", ChartType: chartCumulative, ChartCompareYears: 2}, nil)
+	assert.NoError(t, err)
+	c.years = []comparisonYear{
+		{year: 2016, metrics: []statboard.Metric{{Date: time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), Value: 4.0}}},
+		{year: 2017, metrics: []statboard.Metric{
+			{Date: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), Value: 1.0},
+			{Date: time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC), Value: 2.0},
+		}},
+		{year: 2018, metrics: []statboard.Metric{{Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 3.0}}},
+	}
+
+	chart, ext := c.getComparisonChart()
+
+	assert.Len(t, chart.Data.Datasets, 3)
+	assert.Equal(t, "2018", chart.Data.Datasets[0].Label)
+	assert.Equal(t, "2017", chart.Data.Datasets[1].Label)
+	assert.Equal(t, "2016", chart.Data.Datasets[2].Label)
+	assert.Equal(t, []chartjs.Point{{X: "01-Jan-2018", Y: 1}, {X: "01-Feb-2018", Y: 3}}, chart.Data.Datasets[1].Data)
+	assert.Equal(t, []chartjs.Point{{X: "01-Mar-2018", Y: 4}}, chart.Data.Datasets[2].Data)
+
+	assert.Equal(t, "rgb(52,103,92)", chart.Data.Datasets[0].BackgroundColor)
+	assert.Equal(t, "rgba(52,103,92,0.5)", chart.Data.Datasets[1].BackgroundColor)
+	assert.Equal(t, "rgba(52,103,92,0.25)", chart.Data.Datasets[2].BackgroundColor)
+	assert.Equal(t, "rgba(52,103,92,0.25)", ext.Datasets[2]["borderColor"])
+	assert.Equal(t, false, ext.Datasets[1]["fill"])
+
+	assert.Equal(t, "month", chart.Options.Scales.XAxes[0].Time.Unit)
+	assert.Equal(t, "01-Jan-2018", ext.XAxes[0]["time"].(jsonObject)["min"])
+	assert.Equal(t, "01-Dec-2018", ext.XAxes[0]["time"].(jsonObject)["max"])
+
+	s, err := c.renderChart()
+	assert.NoError(t, err)
+	assert.Contains(t, s, `"label": "2016"`)
+	assert.Contains(t, s, `"tooltipFormat": "MMMM"`)
+}
+
+func TestCompareFadeColor(t *testing.T) {
+	assert.Equal(t, "rgba(52,103,92,0.5)", fadeColor("rgb(52,103,92)", 0.5))
+	assert.Equal(t, "not a color", fadeColor("not a color", 0.5))
+}
@@ -0,0 +1,84 @@
+package reporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+var downloadCSVHeader = []string{"date", "period", "value"}
+
+// handleChartDownload returns the series behind a dashboard chart as CSV or
+// JSON. Without from and to it is the series shown on the chart; either
+// date overrides that window, with from inclusive and to exclusive.
+func (s *Server) handleChartDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		met, ok := s.lookupMetric(vars["metric"])
+		if !ok {
+			http.Error(w, fmt.Sprintf("metric %q is not configured", vars["metric"]), http.StatusNotFound)
+			return
+		}
+
+		now := time.Now().UTC()
+		series := apiSeries{Metric: met.Name, Granularity: met.Granularity, From: chartSince(met.Config, now), To: now}
+		query := r.URL.Query()
+		override := query.Get("from") != "" || query.Get("to") != ""
+		for param, t := range map[string]*time.Time{"from": &series.From, "to": &series.To} {
+			if v := query.Get(param); v != "" {
+				parsed, err := time.Parse(apiDateFormat, v)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %q date %q, expected YYYY-MM-DD", param, v), http.StatusBadRequest)
+					return
+				}
+				*t = parsed
+			}
+		}
+		if !series.From.Before(series.To) {
+			http.Error(w, "'from' must be before 'to'", http.StatusBadRequest)
+			return
+		}
+
+		var metrics []statboard.Metric
+		var err error
+		if override {
+			metrics, err = s.store.GetMetricRange(met.Name, met.Granularity, series.From, series.To)
+		} else {
+			// the same query as the chart
+			metrics, err = s.store.GetMetric(met.Name, met.Granularity, series.From)
+		}
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, "failed to get metric", http.StatusInternalServerError)
+			return
+		}
+		series.Points = []apiPoint{}
+		for _, m := range metrics {
+			series.Points = append(series.Points, apiPoint{Period: met.Granularity.Period(m.Date), Date: m.Date, Value: m.Value})
+		}
+
+		format := vars["format"]
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", met.Name+"."+format))
+		if format == "json" {
+			writeJSON(w, http.StatusOK, series)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write(downloadCSVHeader)
+		for _, p := range series.Points {
+			cw.Write([]string{p.Date.Format(apiDateFormat), p.Period, strconv.FormatFloat(p.Value, 'f', -1, 64)})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChartDownload(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// a value inside the chart's six month window
+	recent := statboard.Monthly.Truncate(time.Now().AddDate(0, -1, 0))
+	assert.NoError(t, srv.store.WriteMetric(statboard.Metric{Name: "github.contributions", Date: recent, Value: 11.0}))
+
+	tt := []struct {
+		name     string
+		metric   string
+		format   string
+		query    string
+		code     int
+		expected string
+	}{
+		{
+			name:     "chart window csv",
+			metric:   "github.contributions",
+			format:   "csv",
+			code:     http.StatusOK,
+			expected: "date,period,value\n" + recent.Format("2006-01-02") + "," + recent.Format("2006-01") + ",11\n",
+		},
+		{
+			name:     "override range csv",
+			metric:   "github.contributions",
+			format:   "csv",
+			query:    "?from=2018-01-01&to=2018-03-01",
+			code:     http.StatusOK,
+			expected: "date,period,value\n2018-01-01,2018-01,5\n2018-02-01,2018-02,7\n",
+		},
+		{
+			name:     "invalid date",
+			metric:   "github.contributions",
+			format:   "csv",
+			query:    "?to=March",
+			code:     http.StatusBadRequest,
+			expected: "invalid \"to\" date \"March\", expected YYYY-MM-DD\n",
+		},
+		{
+			name:     "unknown metric",
+			metric:   "github.stars",
+			format:   "csv",
+			code:     http.StatusNotFound,
+			expected: "metric \"github.stars\" is not configured\n",
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/charts/"+ts.metric+"."+ts.format+ts.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"metric": ts.metric, "format": ts.format})
+			srv.handleChartDownload()(w, req)
+
+			assert.Equal(t, ts.code, w.Code)
+			assert.Equal(t, ts.expected, w.Body.String())
+		})
+	}
+}
+
+func TestChartDownload_JSON(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	srv.downloadRoutes()
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/charts/github.contributions.json?from=2018-02-01", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="github.contributions.json"`, w.Header().Get("Content-Disposition"))
+
+	var series apiSeries
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+	assert.Equal(t, time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), series.From)
+	assert.Equal(t, []apiPoint{
+		{Period: "2018-02", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Value: 7.0},
+		{Period: "2018-03", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 9.0},
+	}, series.Points)
+}
@@ -0,0 +1,112 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// grafanaQuery is the body of a Grafana simple JSON datasource query
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series as Grafana expects it. Every datapoint is a
+// value and a Unix timestamp in milliseconds.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// handleGrafanaTest answers the connection test of the Grafana datasource
+func (s *Server) handleGrafanaTest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// handleGrafanaSearch returns the names of the configured metrics that contain
+// the requested target
+func (s *Server) handleGrafanaSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Target string `json:"target"`
+		}
+		// Grafana sends an empty body when listing every metric
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid search: %s", err))
+				return
+			}
+		}
+
+		names := []string{}
+		for sourceName, metCfgs := range s.cfg.Metrics {
+			for metName := range metCfgs {
+				name := fmt.Sprintf("%s.%s", sourceName, metName)
+				if strings.Contains(name, req.Target) {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+
+		writeJSON(w, http.StatusOK, names)
+	}
+}
+
+// handleGrafanaQuery returns the stored values of every target in the range.
+// A value is included if its period overlaps the range.
+func (s *Server) handleGrafanaQuery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req grafanaQuery
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid query: %s", err))
+			return
+		}
+		if !req.Range.From.Before(req.Range.To) {
+			writeError(w, http.StatusBadRequest, "query range 'from' must be before 'to'")
+			return
+		}
+
+		series := []grafanaSeries{}
+		for _, target := range req.Targets {
+			if target.Type != "" && target.Type != "timeserie" && target.Type != "timeseries" {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported query type %q for %q, only time series are supported", target.Type, target.Target))
+				return
+			}
+			met, ok := s.lookupMetric(target.Target)
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("metric %q is not configured", target.Target))
+				return
+			}
+
+			metrics, err := s.store.GetMetricRange(met.Name, met.Granularity, met.Granularity.Truncate(req.Range.From), req.Range.To)
+			if err != nil {
+				logrus.Error(err)
+				writeError(w, http.StatusInternalServerError, "failed to get metric")
+				return
+			}
+			datapoints := [][2]float64{}
+			for _, m := range metrics {
+				datapoints = append(datapoints, [2]float64{m.Value, float64(m.Date.UnixNano() / int64(time.Millisecond))})
+			}
+			series = append(series, grafanaSeries{Target: met.Name, Datapoints: datapoints})
+		}
+
+		writeJSON(w, http.StatusOK, series)
+	}
+}
@@ -0,0 +1,101 @@
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveGrafana(srv *Server, method string, target string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestGrafanaTest(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	srv.grafanaRoutes()
+
+	w := serveGrafana(srv, http.MethodGet, "/grafana/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGrafanaSearch(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	srv.grafanaRoutes()
+
+	tt := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{name: "empty body", expected: []string{"github.contributions", "goodreads.books_read"}},
+		{name: "empty target", body: `{"target": ""}`, expected: []string{"github.contributions", "goodreads.books_read"}},
+		{name: "filtered", body: `{"target": "books"}`, expected: []string{"goodreads.books_read"}},
+		{name: "no match", body: `{"target": "steps"}`, expected: []string{}},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			w := serveGrafana(srv, http.MethodPost, "/grafana/search", ts.body)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var actual []string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actual))
+			assert.Equal(t, ts.expected, actual)
+		})
+	}
+}
+
+func TestGrafanaQuery(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+	srv.grafanaRoutes()
+
+	tt := []struct {
+		name     string
+		body     string
+		code     int
+		expected string
+	}{
+		{
+			name: "range inside a period",
+			body: `{"range": {"from": "2018-01-15T00:00:00Z", "to": "2018-02-15T00:00:00Z"},
+				"targets": [{"target": "github.contributions", "refId": "A", "type": "timeserie"}, {"target": "goodreads.books_read", "refId": "B"}]}`,
+			code:     http.StatusOK,
+			expected: `[{"target":"github.contributions","datapoints":[[5,1514764800000],[7,1517443200000]]},{"target":"goodreads.books_read","datapoints":[]}]`,
+		},
+		{
+			name:     "unknown metric",
+			body:     `{"range": {"from": "2018-01-01T00:00:00Z", "to": "2018-02-01T00:00:00Z"}, "targets": [{"target": "github.stars"}]}`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":"metric \"github.stars\" is not configured"}`,
+		},
+		{
+			name:     "table query",
+			body:     `{"range": {"from": "2018-01-01T00:00:00Z", "to": "2018-02-01T00:00:00Z"}, "targets": [{"target": "github.contributions", "type": "table"}]}`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":"unsupported query type \"table\" for \"github.contributions\", only time series are supported"}`,
+		},
+		{
+			name:     "empty range",
+			body:     `{"targets": [{"target": "github.contributions"}]}`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":"query range 'from' must be before 'to'"}`,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			w := serveGrafana(srv, http.MethodPost, "/grafana/query", ts.body)
+			assert.Equal(t, ts.code, w.Code)
+			assert.JSONEq(t, ts.expected, w.Body.String())
+		})
+	}
+}
@@ -12,12 +12,31 @@ func (s *Server) handleDashboard() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		charts, err := s.getChartJs()
 		if err != nil {
-			logrus.Fatal(err)
+			logrus.Error(err)
+			http.Error(w, "failed to load charts", http.StatusInternalServerError)
+			return
 		}
 
 		err = tmpl.Execute(w, charts)
 		if err != nil {
-			logrus.Fatal(err)
+			logrus.Error(err)
+		}
+	}
+}
+
+func (s *Server) handleStatus() http.HandlerFunc {
+	tmpl := template.Must(template.ParseFiles("templates/status.html"))
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses, err := s.getStatuses()
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, "failed to load collection status", http.StatusInternalServerError)
+			return
+		}
+
+		err = tmpl.Execute(w, statuses)
+		if err != nil {
+			logrus.Error(err)
 		}
 	}
 }
@@ -0,0 +1,98 @@
+package reporter
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+)
+
+const (
+	// heatmapWeeks is how many weeks a calendar heatmap covers, ending with the current one
+	heatmapWeeks = 53
+	// heatmapCell is the size of a day in pixels, including the gap to the next one
+	heatmapCell = 13
+	// heatmapLeft and heatmapTop leave room for the weekday and month labels
+	heatmapLeft = 30
+	heatmapTop  = 15
+	// heatmapEmpty is the color of days without a value
+	heatmapEmpty = "#ebedf0"
+)
+
+// heatmapOpacity is the opacity of the chart color at each level of the
+// scale. Level 0 is drawn in the opaque empty color.
+var heatmapOpacity = []float64{1, 0.25, 0.5, 0.75, 1}
+
+// heatmapStart returns the first day shown on a heatmap drawn at now
+func heatmapStart(now time.Time) time.Time {
+	return statboard.Weekly.Truncate(now).AddDate(0, 0, -7*(heatmapWeeks-1))
+}
+
+// renderHeatmap draws the daily metrics of the chart as a calendar heatmap
+// with a column per week, starting on Monday. Days are shaded in four steps
+// of the chart color up to the largest value.
+func (c *chart) renderHeatmap(now time.Time) template.HTML {
+	values := make(map[time.Time]float64)
+	var max float64
+	for _, m := range c.metrics {
+		values[statboard.Daily.Truncate(m.Date)] = m.Value
+		if m.Value > max {
+			max = m.Value
+		}
+	}
+
+	start := heatmapStart(now)
+	today := statboard.Daily.Truncate(now)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" id="%s" width="%d" height="%d" font-family="fira mono,monospace" font-size="9">`,
+		c.metricName, heatmapLeft+heatmapWeeks*heatmapCell, heatmapTop+7*heatmapCell)
+	for row, label := range []string{"Mon", "", "Wed", "", "Fri", "", ""} {
+		if label != "" {
+			fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, heatmapTop+row*heatmapCell+9, label)
+		}
+	}
+
+	for week := 0; week < heatmapWeeks; week++ {
+		monday := start.AddDate(0, 0, 7*week)
+		x := heatmapLeft + week*heatmapCell
+		if week == 0 || monday.Month() != monday.AddDate(0, 0, -7).Month() {
+			fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, x, heatmapTop-4, monday.Format("Jan"))
+		}
+
+		for day := 0; day < 7; day++ {
+			date := monday.AddDate(0, 0, day)
+			if date.After(today) {
+				break
+			}
+			value := values[date]
+			level := heatmapLevel(value, max)
+			fill := heatmapEmpty
+			if level > 0 {
+				fill = c.color
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%g"><title>%s: %g</title></rect>`,
+				x, heatmapTop+day*heatmapCell, heatmapCell-2, heatmapCell-2, fill, heatmapOpacity[level],
+				date.Format("2006-01-02"), value)
+		}
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// heatmapLevel returns the step of the color scale for value, 0 for no activity
+func heatmapLevel(value float64, max float64) int {
+	if value <= 0 || max <= 0 {
+		return 0
+	}
+	steps := len(heatmapOpacity) - 1
+	for level := 1; level < steps; level++ {
+		if value <= max*float64(level)/float64(steps) {
+			return level
+		}
+	}
+	return steps
+}
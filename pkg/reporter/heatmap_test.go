@@ -0,0 +1,54 @@
+package reporter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeatmapLevel(t *testing.T) {
+	tt := []struct {
+		value    float64
+		max      float64
+		expected int
+	}{
+		{value: 0, max: 0, expected: 0},
+		{value: 0, max: 8, expected: 0},
+		{value: 1, max: 8, expected: 1},
+		{value: 2, max: 8, expected: 1},
+		{value: 3, max: 8, expected: 2},
+		{value: 6, max: 8, expected: 3},
+		{value: 7, max: 8, expected: 4},
+		{value: 8, max: 8, expected: 4},
+	}
+
+	for _, ts := range tt {
+		assert.Equal(t, ts.expected, heatmapLevel(ts.value, ts.max), "%g of %g", ts.value, ts.max)
+	}
+}
+
+func TestChartRenderHeatmap(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2018, 3, 14, 15, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC), heatmapStart(now))
+
+	metrics := []statboard.Metric{
+		{Name: "github.contributions", Date: time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), Value: 2, Granularity: statboard.Daily},
+		{Name: "github.contributions", Date: time.Date(2018, 3, 13, 0, 0, 0, 0, time.UTC), Value: 8, Granularity: statboard.Daily},
+	}
+	c, err := newChart("github.contributions", config.MetricConfig{ChartColor: "#4286f4", ChartType: chartHeatmap, Granularity: statboard.Daily}, metrics)
+	assert.NoError(t, err)
+
+	svg := string(c.renderHeatmap(now))
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" id="github_contributions"`))
+	// 52 full weeks and Monday to Wednesday of the current one
+	assert.Equal(t, 52*7+3, strings.Count(svg, "<rect"))
+	assert.Contains(t, svg, `fill="rgb(66,134,244)" fill-opacity="0.25"><title>2018-03-12: 2</title>`)
+	assert.Contains(t, svg, `fill="rgb(66,134,244)" fill-opacity="1"><title>2018-03-13: 8</title>`)
+	assert.Contains(t, svg, `fill="#ebedf0" fill-opacity="1"><title>2018-03-14: 0</title>`)
+	assert.Contains(t, svg, `>Mar</text>`)
+}
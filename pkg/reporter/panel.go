@@ -0,0 +1,168 @@
+package reporter
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	chartjs "github.com/ajbosco/goChartjs"
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// axisLeft is the primary y-axis of a panel
+	axisLeft = "left"
+	// axisRight is the secondary y-axis of a panel
+	axisRight = "right"
+)
+
+// panel combines the charts of several metrics into one chart.js chart
+type panel struct {
+	metricName string
+	ChartName  string
+	charts     []chart
+	axes       []string
+}
+
+// validatePanel checks that every metric of a panel is configured and can be drawn with the others
+func validatePanel(metrics map[string]map[string]config.MetricConfig, panelCfg config.PanelConfig) error {
+	if panelCfg.ChartName == "" {
+		return errors.New("every panel needs a 'chart_name'")
+	}
+	if len(panelCfg.Metrics) == 0 {
+		return fmt.Errorf("panel %q has no metrics", panelCfg.ChartName)
+	}
+
+	var granularity string
+	for _, pm := range panelCfg.Metrics {
+		met, ok := findMetric(metrics, pm.Metric)
+		if !ok {
+			return fmt.Errorf("panel %q: metric %q is not configured", panelCfg.ChartName, pm.Metric)
+		}
+		if pm.Axis != "" && pm.Axis != axisLeft && pm.Axis != axisRight {
+			return fmt.Errorf("panel %q: unsupported axis %q for %q, valid axes are: left, right", panelCfg.ChartName, pm.Axis, pm.Metric)
+		}
+		if chartTypeOrDefault(met.Config.ChartType) == chartHeatmap {
+			return fmt.Errorf("panel %q: heatmap %q cannot be drawn on a panel", panelCfg.ChartName, pm.Metric)
+		}
+		if granularity == "" {
+			granularity = string(met.Granularity)
+		} else if string(met.Granularity) != granularity {
+			return fmt.Errorf("panel %q: all metrics must have the same granularity", panelCfg.ChartName)
+		}
+	}
+	return nil
+}
+
+// getPanel fetches the metrics of a panel and returns it rendered as a chart
+func (s *Server) getPanel(panelCfg config.PanelConfig, now time.Time) (chart, error) {
+	p := panel{metricName: "panel_" + validJSName(panelCfg.ChartName), ChartName: panelCfg.ChartName}
+
+	for _, pm := range panelCfg.Metrics {
+		met, ok := s.lookupMetric(pm.Metric)
+		if !ok {
+			return chart{}, fmt.Errorf("panel %q: metric %q is not configured", panelCfg.ChartName, pm.Metric)
+		}
+		since := met.Granularity.Truncate(now.AddDate(0, -panelCfg.ChartMonthsBack, 0))
+		metrics, err := s.store.GetMetric(met.Name, met.Granularity, since)
+		if err != nil {
+			return chart{}, errors.Wrap(err, "failed to get metric")
+		}
+		c, err := newChart(met.Name, met.Config, metrics)
+		if err != nil {
+			return chart{}, errors.Wrap(err, "failed to create new chart")
+		}
+		axis := pm.Axis
+		if axis == "" {
+			axis = axisLeft
+		}
+		p.charts = append(p.charts, c)
+		p.axes = append(p.axes, axis)
+	}
+
+	chartString, err := p.renderChart()
+	if err != nil {
+		return chart{}, errors.Wrap(err, "failed to render panel")
+	}
+	return chart{metricName: p.metricName, ChartName: p.ChartName, ChartJS: template.HTML(chartString)}, nil
+}
+
+// renderChart draws every chart of the panel as a dataset of one chart
+func (p *panel) renderChart() (string, error) {
+	combined, ext := p.getChart()
+
+	s, err := renderChartJS(combined, ext)
+	if err != nil {
+		return "", errors.Wrap(err, "rendering chart failed")
+	}
+	return s, nil
+}
+
+// getChart combines the datasets and y-axis options of every chart of the panel
+func (p *panel) getChart() (chartjs.Chart, chartjsExtensions) {
+	var combined chartjs.Chart
+	var ext chartjsExtensions
+	yAxes := map[string]jsonObject{}
+	var hasBar bool
+
+	for i, c := range p.charts {
+		chartData := metricsToPoints(c.metrics)
+		if c.chartType == chartCumulative {
+			chartData = cumulativePoints(chartData)
+		}
+		cj, cext := c.getChart(chartData)
+		if i == 0 {
+			combined = cj
+			combined.Name = p.metricName
+			combined.Data.Datasets = nil
+			combined.Options.Scales.YAxes = nil
+		}
+
+		dataset := cj.Data.Datasets[0]
+		dataset.Label = c.ChartName
+		if dataset.Label == "" {
+			dataset.Label = c.Metric
+		}
+		combined.Data.Datasets = append(combined.Data.Datasets, dataset)
+
+		// the first chart on an axis sets its label and range
+		datasetExt := cext.Datasets[0]
+		datasetExt["borderColor"] = c.color
+		datasetExt["yAxisID"] = p.axes[i]
+		datasetExt["type"] = cj.ChartType
+		hasBar = hasBar || cj.ChartType == chartBar
+		ext.Datasets = append(ext.Datasets, datasetExt)
+		if _, ok := yAxes[p.axes[i]]; !ok {
+			yAxes[p.axes[i]] = cext.YAxes[0]
+		}
+	}
+
+	if hasBar {
+		combined.ChartType = chartBar
+	}
+	for _, axis := range []string{axisLeft, axisRight} {
+		axisExt, ok := yAxes[axis]
+		if !ok {
+			continue
+		}
+		axisExt["id"] = axis
+		axisExt["position"] = axis
+		combined.Options.Scales.YAxes = append(combined.Options.Scales.YAxes, chartjs.Axes{Ticks: &chartjs.Ticks{BeginAtZero: true}})
+		ext.YAxes = append(ext.YAxes, axisExt)
+	}
+
+	return combined, ext
+}
+
+// validJSName returns name with every character that cannot be part of a
+// JavaScript identifier replaced
+func validJSName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
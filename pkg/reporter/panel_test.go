@@ -0,0 +1,80 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/config"
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanelValidatePanel(t *testing.T) {
+	metrics := map[string]map[string]config.MetricConfig{
+		"goodreads": {
+			"books_read": {},
+			"pages_read": {ChartType: chartCumulative},
+		},
+		"fitbit": {
+			"steps":  {ChartType: chartHeatmap, Granularity: statboard.Daily},
+			"floors": {Granularity: statboard.Daily},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		panelCfg config.PanelConfig
+		err      string
+	}{
+		{"valid", config.PanelConfig{ChartName: "Reading", Metrics: []config.PanelMetricConfig{{Metric: "goodreads.books_read"}, {Metric: "goodreads.pages_read", Axis: "right"}}}, ""},
+		{"no name", config.PanelConfig{Metrics: []config.PanelMetricConfig{{Metric: "goodreads.books_read"}}}, "every panel needs a 'chart_name'"},
+		{"no metrics", config.PanelConfig{ChartName: "Reading"}, `panel "Reading" has no metrics`},
+		{"unknown metric", config.PanelConfig{ChartName: "Reading", Metrics: []config.PanelMetricConfig{{Metric: "goodreads.nope"}}}, `panel "Reading": metric "goodreads.nope" is not configured`},
+		{"bad axis", config.PanelConfig{ChartName: "Reading", Metrics: []config.PanelMetricConfig{{Metric: "goodreads.books_read", Axis: "top"}}}, `panel "Reading": unsupported axis "top" for "goodreads.books_read", valid axes are: left, right`},
+		{"heatmap", config.PanelConfig{ChartName: "Fitness", Metrics: []config.PanelMetricConfig{{Metric: "fitbit.steps"}}}, `panel "Fitness": heatmap "fitbit.steps" cannot be drawn on a panel`},
+		{"mixed granularity", config.PanelConfig{ChartName: "Mixed", Metrics: []config.PanelMetricConfig{{Metric: "goodreads.books_read"}, {Metric: "fitbit.floors"}}}, `panel "Mixed": all metrics must have the same granularity`},
+	}
+
+	for _, ts := range testCases {
+		t.Run(ts.name, func(t *testing.T) {
+			err := validatePanel(metrics, ts.panelCfg)
+			if ts.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, ts.err)
+			}
+		})
+	}
+}
+
+func TestPanelGetChart(t *testing.T) {
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	books, err := newChart("goodreads.books_read", config.MetricConfig{ChartName: "Books Read", ChartColor: "#34675C", ChartType: chartBar},
+		[]statboard.Metric{{Name: "goodreads.books_read", Date: testTime, Value: 2.0}})
+	assert.NoError(t, err)
+	pages, err := newChart("goodreads.pages_read", config.MetricConfig{ChartColor: "#7DA3A1", ChartType: chartCumulative, ChartUnit: "pages"},
+		[]statboard.Metric{{Name: "goodreads.pages_read", Date: testTime, Value: 300.0}, {Name: "goodreads.pages_read", Date: testTime.AddDate(0, 1, 0), Value: 200.0}})
+	assert.NoError(t, err)
+
+	p := panel{metricName: "panel_Reading", ChartName: "Reading", charts: []chart{books, pages}, axes: []string{axisLeft, axisRight}}
+	combined, ext := p.getChart()
+
+	assert.Equal(t, "panel_Reading", combined.Name)
+	assert.Equal(t, chartBar, combined.ChartType)
+	assert.Len(t, combined.Data.Datasets, 2)
+	assert.Equal(t, "Books Read", combined.Data.Datasets[0].Label)
+	assert.Equal(t, "goodreads.pages_read", combined.Data.Datasets[1].Label)
+	assert.Equal(t, 500.0, combined.Data.Datasets[1].Data[1].Y)
+	assert.Len(t, combined.Options.Scales.YAxes, 2)
+
+	assert.Equal(t, jsonObject{"borderColor": "rgb(52,103,92)", "yAxisID": axisLeft, "type": chartBar}, ext.Datasets[0])
+	assert.Equal(t, jsonObject{"fill": false, "borderColor": "rgb(125,163,161)", "yAxisID": axisRight, "type": chartLine}, ext.Datasets[1])
+	assert.Equal(t, jsonObject{"id": axisLeft, "position": axisLeft}, ext.YAxes[0])
+	assert.Equal(t, jsonObject{"id": axisRight, "position": axisRight, "scaleLabel": jsonObject{"display": true, "labelString": "pages"}}, ext.YAxes[1])
+
+	s, err := p.renderChart()
+	assert.NoError(t, err)
+	assert.Contains(t, s, `var panel_Reading = new Chart(ctx,`)
+	assert.Contains(t, s, `"yAxisID": "right"`)
+	assert.Contains(t, s, `"position": "right"`)
+}
@@ -0,0 +1,145 @@
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// promContentType is the content type of the Prometheus text exposition format
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promFamily is a Prometheus metric and its samples
+type promFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []promSample
+}
+
+// promSample is a single labelled value of a promFamily
+type promSample struct {
+	source string
+	metric string
+	value  float64
+}
+
+func (f *promFamily) add(source string, metric string, value float64) {
+	f.samples = append(f.samples, promSample{source: source, metric: metric, value: value})
+}
+
+// handlePrometheus serves the stored metrics and collection health for Prometheus
+func (s *Server) handlePrometheus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		families, err := s.getPrometheusFamilies()
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, "failed to load metrics", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", promContentType)
+		if err := writePrometheus(w, families); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+// getPrometheusFamilies reads the latest value and run history of every configured metric
+func (s *Server) getPrometheusFamilies() ([]*promFamily, error) {
+	value := &promFamily{name: "statboard_metric_value", help: "Latest stored value of the metric.", typ: "gauge"}
+	period := &promFamily{name: "statboard_metric_period_start_seconds", help: "Start of the period of the latest stored value as a Unix timestamp.", typ: "gauge"}
+	lastSuccess := &promFamily{name: "statboard_collector_last_success_timestamp_seconds", help: "End of the last successful collection as a Unix timestamp.", typ: "gauge"}
+	lastDuration := &promFamily{name: "statboard_collector_last_run_duration_seconds", help: "Duration of the last collection.", typ: "gauge"}
+	lastFailed := &promFamily{name: "statboard_collector_last_run_failed", help: "Whether the last collection failed.", typ: "gauge"}
+	runs := &promFamily{name: "statboard_collector_run_history_runs", help: "Collections in the run history, which keeps the last 100 of each metric. A windowed gauge, not a counter: do not use rate() or increase().", typ: "gauge"}
+	runErrors := &promFamily{name: "statboard_collector_run_history_errors", help: "Failed collections in the run history, which keeps the last 100 of each metric. A windowed gauge that falls as old runs are pruned, not a counter: do not use rate() or increase().", typ: "gauge"}
+
+	var sourceNames []string
+	for sourceName := range s.cfg.Metrics {
+		sourceNames = append(sourceNames, sourceName)
+	}
+	sort.Strings(sourceNames)
+
+	for _, sourceName := range sourceNames {
+		metCfgs := s.cfg.Metrics[sourceName]
+		var metNames []string
+		for metName := range metCfgs {
+			metNames = append(metNames, metName)
+		}
+		sort.Strings(metNames)
+
+		for _, metName := range metNames {
+			metCfg := metCfgs[metName]
+			metricName := fmt.Sprintf("%s.%s", sourceName, metName)
+
+			latest, ok, err := s.store.GetLatestMetric(metricName, metCfg.Granularity.OrDefault())
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to get metric %q", metricName))
+			}
+			if ok {
+				value.add(sourceName, metName, latest.Value)
+				period.add(sourceName, metName, float64(latest.Date.Unix()))
+			}
+
+			history, err := s.store.GetRuns(metricName)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to get runs for %q", metricName))
+			}
+			status := newMetricStatus(metricName, metCfg.ChartName, history, metCfg.StaleThreshold(), time.Now())
+			if status.LastSuccess != nil {
+				lastSuccess.add(sourceName, metName, float64(status.LastSuccess.End.Unix()))
+			}
+			if status.LastRun != nil {
+				lastDuration.add(sourceName, metName, status.LastRun.End.Sub(status.LastRun.Start).Seconds())
+				var failed float64
+				if !status.LastRun.Succeeded() {
+					failed = 1
+				}
+				lastFailed.add(sourceName, metName, failed)
+			}
+			var n int
+			for _, run := range history {
+				if !run.Succeeded() {
+					n++
+				}
+			}
+			runs.add(sourceName, metName, float64(len(history)))
+			runErrors.add(sourceName, metName, float64(n))
+		}
+	}
+
+	return []*promFamily{value, period, lastSuccess, lastDuration, lastFailed, runs, runErrors}, nil
+}
+
+// writePrometheus writes the families in the Prometheus text exposition format
+func writePrometheus(w io.Writer, families []*promFamily) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, sample := range f.samples {
+			fmt.Fprintf(bw, "%s{source=\"%s\",metric=\"%s\"} %s\n", f.name,
+				escapeLabel(sample.source), escapeLabel(sample.metric), strconv.FormatFloat(sample.value, 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for the text exposition format
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
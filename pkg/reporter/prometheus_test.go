@@ -0,0 +1,67 @@
+package reporter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	srv, cleanup := newTestServer(t)
+	defer cleanup()
+
+	start := time.Date(2018, 4, 1, 3, 0, 0, 0, time.UTC)
+	for _, run := range []statboard.Run{
+		{Metric: "github.contributions", Start: start, End: start.Add(2 * time.Second), Records: 3},
+		{Metric: "github.contributions", Start: start.Add(time.Hour), End: start.Add(time.Hour + 1500*time.Millisecond), Error: "api error"},
+		{Metric: "goodreads.books_read", Start: start, End: start.Add(time.Second), Error: "timeout"},
+	} {
+		assert.NoError(t, srv.store.WriteRun(run))
+	}
+
+	w := httptest.NewRecorder()
+	srv.handlePrometheus()(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, promContentType, w.Header().Get("Content-Type"))
+
+	expected := `# HELP statboard_metric_value Latest stored value of the metric.
+# TYPE statboard_metric_value gauge
+statboard_metric_value{source="github",metric="contributions"} 9
+# HELP statboard_metric_period_start_seconds Start of the period of the latest stored value as a Unix timestamp.
+# TYPE statboard_metric_period_start_seconds gauge
+statboard_metric_period_start_seconds{source="github",metric="contributions"} 1.5198624e+09
+# HELP statboard_collector_last_success_timestamp_seconds End of the last successful collection as a Unix timestamp.
+# TYPE statboard_collector_last_success_timestamp_seconds gauge
+statboard_collector_last_success_timestamp_seconds{source="github",metric="contributions"} 1.522551602e+09
+# HELP statboard_collector_last_run_duration_seconds Duration of the last collection.
+# TYPE statboard_collector_last_run_duration_seconds gauge
+statboard_collector_last_run_duration_seconds{source="github",metric="contributions"} 1.5
+statboard_collector_last_run_duration_seconds{source="goodreads",metric="books_read"} 1
+# HELP statboard_collector_last_run_failed Whether the last collection failed.
+# TYPE statboard_collector_last_run_failed gauge
+statboard_collector_last_run_failed{source="github",metric="contributions"} 1
+statboard_collector_last_run_failed{source="goodreads",metric="books_read"} 1
+# HELP statboard_collector_run_history_runs Collections in the run history, which keeps the last 100 of each metric. A windowed gauge, not a counter: do not use rate() or increase().
+# TYPE statboard_collector_run_history_runs gauge
+statboard_collector_run_history_runs{source="github",metric="contributions"} 2
+statboard_collector_run_history_runs{source="goodreads",metric="books_read"} 1
+# HELP statboard_collector_run_history_errors Failed collections in the run history, which keeps the last 100 of each metric. A windowed gauge that falls as old runs are pruned, not a counter: do not use rate() or increase().
+# TYPE statboard_collector_run_history_errors gauge
+statboard_collector_run_history_errors{source="github",metric="contributions"} 1
+statboard_collector_run_history_errors{source="goodreads",metric="books_read"} 1
+`
+	assert.Equal(t, expected, w.Body.String())
+}
+
+func TestPrometheusEscapeLabel(t *testing.T) {
+	var buf bytes.Buffer
+	f := &promFamily{name: "test", help: "Test.", typ: "gauge"}
+	f.add(`a"b`, "c\\d\ne", 1)
+	assert.NoError(t, writePrometheus(&buf, []*promFamily{f}))
+	assert.Contains(t, buf.String(), `test{source="a\"b",metric="c\\d\ne"} 1`)
+}
@@ -2,5 +2,34 @@ package reporter
 
 func (s *Server) routes() {
 	s.router.HandleFunc("/", s.handleDashboard())
+	s.router.HandleFunc("/status", s.handleStatus())
 	s.router.HandleFunc("/favicon.ico", s.handleFavicon())
+	s.router.HandleFunc("/metrics", s.handlePrometheus())
+	s.downloadRoutes()
+	s.apiRoutes()
+	s.grafanaRoutes()
+}
+
+// downloadRoutes registers the csv and json downloads of the dashboard charts
+func (s *Server) downloadRoutes() {
+	s.router.HandleFunc("/charts/{metric}.{format:csv|json}", s.handleChartDownload())
+}
+
+// apiRoutes registers the versioned JSON API
+func (s *Server) apiRoutes() {
+	api := s.router.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/metrics", apiGet(s.handleAPIMetrics()))
+	api.HandleFunc("/metrics/{metric}", apiGet(s.handleAPIMetric()))
+	api.HandleFunc("/metrics/{metric}/series", apiGet(s.handleAPISeries()))
+	api.NotFoundHandler = s.handleAPINotFound()
+}
+
+// grafanaRoutes registers the Grafana simple JSON datasource. Point the
+// datasource at /grafana.
+func (s *Server) grafanaRoutes() {
+	grafana := s.router.PathPrefix("/grafana").Subrouter()
+	grafana.HandleFunc("/", s.handleGrafanaTest())
+	grafana.HandleFunc("/search", s.handleGrafanaSearch())
+	grafana.HandleFunc("/query", s.handleGrafanaQuery())
+	grafana.NotFoundHandler = s.handleAPINotFound()
 }
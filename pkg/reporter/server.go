@@ -31,6 +31,7 @@ func (s *Server) ListenAndServe() {
 
 func (s *Server) getChartJs() ([]chart, error) {
 	var charts []chart
+	now := time.Now()
 
 	// Render charts for all metrics
 	for metType, metCfgs := range s.cfg.Metrics {
@@ -38,31 +39,60 @@ func (s *Server) getChartJs() ([]chart, error) {
 
 			// Fetch metric values from database
 			metricName := fmt.Sprintf("%s.%s", metType, metName)
-			sinceDt := time.Now().AddDate(0, -metCfg.ChartMonthsBack, 0)
-			firstOfMonth := time.Date(sinceDt.Year(), sinceDt.Month(), 1, 0, 0, 0, 0, time.UTC).Truncate(24 * time.Hour)
-			met, err := s.store.GetMetric(metricName, firstOfMonth)
+			granularity := metCfg.Granularity.OrDefault()
+			met, err := s.store.GetMetric(metricName, granularity, chartSince(metCfg, now))
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to get metric")
 			}
-			if len(met) == 0 {
+			var years []comparisonYear
+			if metCfg.ChartCompareYears > 0 {
+				years, err = s.getComparison(metricName, metCfg.ChartCompareYears, now)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if len(met) == 0 && comparisonEmpty(years) {
 				logrus.Info(fmt.Sprintf("no metrics returned for %q", metricName))
 				continue
 			}
 
 			// Render chart for  metric values
-			chart, err := newChart(metricName, metCfg.ChartName, metCfg.ChartColor, met)
+			chart, err := newChart(metricName, metCfg, met)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to create new chart")
 			}
-			chartString, err := chart.renderChart()
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to render chart")
+			chart.years = years
+			if chart.chartType == chartHeatmap {
+				chart.SVG = chart.renderHeatmap(now)
+			} else {
+				chartString, err := chart.renderChart()
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to render chart")
+				}
+				chart.ChartJS = template.HTML(chartString)
 			}
-			chart.ChartJS = template.HTML(chartString)
 
 			// append charts to dashboard
 			charts = append(charts, chart)
 		}
 	}
+
+	// Render panels of several metrics after the metric charts
+	for _, panelCfg := range s.cfg.Panels {
+		panel, err := s.getPanel(panelCfg, now)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, panel)
+	}
 	return charts, nil
 }
+
+// chartSince returns the date a metric's chart starts after, ChartMonthsBack
+// before now. Heatmaps always cover the last year.
+func chartSince(metCfg config.MetricConfig, now time.Time) time.Time {
+	if metCfg.ChartType == chartHeatmap {
+		return heatmapStart(now).AddDate(0, 0, -1)
+	}
+	return metCfg.Granularity.OrDefault().Truncate(now.AddDate(0, -metCfg.ChartMonthsBack, 0))
+}
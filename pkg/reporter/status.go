@@ -0,0 +1,60 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+)
+
+// metricStatus contains the collection health of a metric
+type metricStatus struct {
+	Metric      string
+	ChartName   string
+	LastRun     *statboard.Run
+	LastSuccess *statboard.Run
+	Stale       bool
+}
+
+// newMetricStatus summarizes the run history of a metric. A metric is stale
+// if it has not been collected successfully within staleAfter.
+func newMetricStatus(metricName string, chartName string, runs []statboard.Run, staleAfter time.Duration, now time.Time) metricStatus {
+	status := metricStatus{Metric: metricName, ChartName: chartName}
+
+	for i := range runs {
+		run := runs[i]
+		if status.LastRun == nil || run.Start.After(status.LastRun.Start) {
+			status.LastRun = &run
+		}
+		if run.Succeeded() && (status.LastSuccess == nil || run.End.After(status.LastSuccess.End)) {
+			status.LastSuccess = &run
+		}
+	}
+
+	status.Stale = status.LastSuccess == nil || now.Sub(status.LastSuccess.End) > staleAfter
+
+	return status
+}
+
+// getStatuses returns the collection health of all configured metrics
+func (s *Server) getStatuses() ([]metricStatus, error) {
+	var statuses []metricStatus
+	now := time.Now()
+
+	for metType, metCfgs := range s.cfg.Metrics {
+		for metName, metCfg := range metCfgs {
+			metricName := fmt.Sprintf("%s.%s", metType, metName)
+			runs, err := s.store.GetRuns(metricName)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to get runs for %q", metricName))
+			}
+			statuses = append(statuses, newMetricStatus(metricName, metCfg.ChartName, runs, metCfg.StaleThreshold(), now))
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Metric < statuses[j].Metric })
+
+	return statuses, nil
+}
@@ -0,0 +1,55 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusNewMetricStatus(t *testing.T) {
+	now := time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC)
+	success := statboard.Run{Metric: "testMetric", Start: now.Add(-30 * time.Hour), End: now.Add(-29 * time.Hour), Records: 3}
+	failure := statboard.Run{Metric: "testMetric", Start: now.Add(-time.Hour), End: now.Add(-time.Hour), Error: "api error"}
+
+	tt := []struct {
+		name        string
+		runs        []statboard.Run
+		staleAfter  time.Duration
+		lastRun     *statboard.Run
+		lastSuccess *statboard.Run
+		stale       bool
+	}{
+		{
+			name:       "never collected",
+			staleAfter: time.Hour,
+			stale:      true,
+		},
+		{
+			name:        "recent success",
+			runs:        []statboard.Run{success},
+			staleAfter:  48 * time.Hour,
+			lastRun:     &success,
+			lastSuccess: &success,
+		},
+		{
+			name:        "failing since last success",
+			runs:        []statboard.Run{success, failure},
+			staleAfter:  24 * time.Hour,
+			lastRun:     &failure,
+			lastSuccess: &success,
+			stale:       true,
+		},
+	}
+
+	for _, ts := range tt {
+		t.Run(ts.name, func(t *testing.T) {
+			actual := newMetricStatus("testMetric", "testChart", ts.runs, ts.staleAfter, now)
+			assert.Equal(t, "testMetric", actual.Metric)
+			assert.Equal(t, ts.lastRun, actual.LastRun)
+			assert.Equal(t, ts.lastSuccess, actual.LastSuccess)
+			assert.Equal(t, ts.stale, actual.Stale)
+		})
+	}
+}
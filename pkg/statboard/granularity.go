@@ -0,0 +1,105 @@
+package statboard
+
+import (
+	"fmt"
+	"time"
+)
+
+// Granularity is the length of the period a metric value covers
+type Granularity string
+
+const (
+	// Daily metrics cover a single day
+	Daily Granularity = "day"
+	// Weekly metrics cover a week starting on Monday
+	Weekly Granularity = "week"
+	// Monthly metrics cover a calendar month
+	Monthly Granularity = "month"
+	// Yearly metrics cover a calendar year
+	Yearly Granularity = "year"
+)
+
+// Granularities lists every supported granularity from finest to coarsest
+var Granularities = []Granularity{Daily, Weekly, Monthly, Yearly}
+
+// ParseGranularity returns the Granularity for a config value. An empty value is Monthly.
+func ParseGranularity(s string) (Granularity, error) {
+	if s == "" {
+		return Monthly, nil
+	}
+	for _, g := range Granularities {
+		if string(g) == s {
+			return g, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported granularity %q, valid granularities are: day, week, month, year", s)
+}
+
+// OrDefault returns Monthly for the empty Granularity of metrics written before
+// granularities were introduced.
+func (g Granularity) OrDefault() Granularity {
+	if g == "" {
+		return Monthly
+	}
+	return g
+}
+
+// Truncate returns the start of the period containing the calendar date of t.
+// The result is always in UTC.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	switch g.OrDefault() {
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Weekly:
+		// time.Weekday starts on Sunday, weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+	case Yearly:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Period returns the ISO 8601 name of the period containing the calendar date
+// of t: 2018-03-15, 2018-W11, 2018-03 or 2018
+func (g Granularity) Period(t time.Time) string {
+	start := g.Truncate(t)
+	switch g.OrDefault() {
+	case Daily:
+		return start.Format("2006-01-02")
+	case Weekly:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case Yearly:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// Next returns the start of the period following the one starting at t
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g.OrDefault() {
+	case Daily:
+		return t.AddDate(0, 0, 1)
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Yearly:
+		return t.AddDate(1, 0, 0)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+// RollsUpTo reports whether every period of g lies inside a single, longer
+// period of coarser, so values of g can be summed into it
+func (g Granularity) RollsUpTo(coarser Granularity) bool {
+	switch g.OrDefault() {
+	case Daily:
+		return coarser.OrDefault() != Daily
+	case Monthly:
+		return coarser == Yearly
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package statboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGranularityTruncate(t *testing.T) {
+	// Thursday
+	testDate := time.Date(2018, 3, 15, 20, 34, 58, 0, time.UTC)
+
+	tt := []struct {
+		granularity Granularity
+		expected    time.Time
+		next        time.Time
+		period      string
+	}{
+		{Daily, time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC), "2018-03-15"},
+		{Weekly, time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC), "2018-W11"},
+		{Monthly, time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC), "2018-03"},
+		{Yearly, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), "2018"},
+		{"", time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC), "2018-03"},
+	}
+
+	for _, ts := range tt {
+		t.Run(string(ts.granularity), func(t *testing.T) {
+			actual := ts.granularity.Truncate(testDate)
+			assert.Equal(t, ts.expected, actual)
+			assert.Equal(t, ts.next, ts.granularity.Next(actual))
+			assert.Equal(t, ts.period, ts.granularity.Period(testDate))
+		})
+	}
+}
+
+func TestGranularityTruncate_Sunday(t *testing.T) {
+	sunday := time.Date(2018, 3, 18, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2018, 3, 12, 0, 0, 0, 0, time.UTC), Weekly.Truncate(sunday))
+}
+
+func TestParseGranularity(t *testing.T) {
+	g, err := ParseGranularity("")
+	assert.NoError(t, err)
+	assert.Equal(t, Monthly, g)
+
+	g, err = ParseGranularity("week")
+	assert.NoError(t, err)
+	assert.Equal(t, Weekly, g)
+
+	_, err = ParseGranularity("hour")
+	assert.Error(t, err)
+}
+
+func TestGranularityRollsUpTo(t *testing.T) {
+	tt := []struct {
+		from     Granularity
+		to       Granularity
+		expected bool
+	}{
+		{from: Daily, to: Weekly, expected: true},
+		{from: Daily, to: Monthly, expected: true},
+		{from: Daily, to: Daily, expected: false},
+		{from: Weekly, to: Monthly, expected: false},
+		{from: Monthly, to: Yearly, expected: true},
+		{from: "", to: Yearly, expected: true},
+		{from: Yearly, to: Monthly, expected: false},
+	}
+
+	for _, ts := range tt {
+		assert.Equal(t, ts.expected, ts.from.RollsUpTo(ts.to), "%s to %s", ts.from, ts.to)
+	}
+}
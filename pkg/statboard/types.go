@@ -4,8 +4,41 @@ import "time"
 
 // Metric contains information about each metric
 type Metric struct {
-	ID    string `storm:"id"`
-	Name  string
-	Date  time.Time
-	Value float64
+	ID          string `storm:"id"`
+	Name        string
+	Date        time.Time
+	Value       float64
+	Granularity Granularity
+}
+
+// Run contains the outcome of collecting a metric
+type Run struct {
+	ID      string `storm:"id"`
+	Metric  string `storm:"index"`
+	Start   time.Time
+	End     time.Time
+	Records int
+	Error   string
+}
+
+// Succeeded returns true if the run finished without an error
+func (r Run) Succeeded() bool {
+	return r.Error == ""
+}
+
+// Event contains a single raw observation collected from a source
+type Event struct {
+	ID         string `storm:"id"`
+	Source     string `storm:"index"`
+	Kind       string
+	Timestamp  time.Time `storm:"index"`
+	Value      float64
+	Attributes map[string]string
+}
+
+// Checkpoint contains the high-water mark up to which a metric has been collected
+type Checkpoint struct {
+	Metric        string `storm:"id"`
+	HighWaterMark time.Time
+	Updated       time.Time
 }
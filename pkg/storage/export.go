@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+)
+
+// Format is a file format metrics can be exported to and imported from
+type Format string
+
+const (
+	// JSONLines writes one JSON object per record per line. Besides metrics
+	// it holds the events, runs and checkpoints of every stored metric.
+	JSONLines Format = "jsonl"
+	// CSV writes a header row followed by one row per metric. It holds
+	// metrics only.
+	CSV Format = "csv"
+)
+
+// ParseFormat returns the Format for a flag value. An empty value is JSONLines.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return JSONLines, nil
+	case JSONLines, CSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, valid formats are: jsonl, csv", s)
+}
+
+// Record types of a JSON Lines export. Lines without a type are metrics, as
+// in files exported before the other records were.
+const (
+	metricType     = "metric"
+	eventType      = "event"
+	runType        = "run"
+	checkpointType = "checkpoint"
+)
+
+// metricRecord is a metric as it is exported
+type metricRecord struct {
+	Type        string                `json:"type"`
+	Name        string                `json:"name"`
+	Granularity statboard.Granularity `json:"granularity"`
+	Period      string                `json:"period"`
+	Date        time.Time             `json:"date"`
+	Value       float64               `json:"value"`
+}
+
+// eventRecord is a raw event as it is exported
+type eventRecord struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Source     string            `json:"source"`
+	Kind       string            `json:"kind"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Value      float64           `json:"value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// runRecord is a collection run as it is exported
+type runRecord struct {
+	Type    string    `json:"type"`
+	Metric  string    `json:"metric"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Records int       `json:"records"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// checkpointRecord is a collection checkpoint as it is exported
+type checkpointRecord struct {
+	Type          string    `json:"type"`
+	Metric        string    `json:"metric"`
+	HighWaterMark time.Time `json:"high_water_mark"`
+	Updated       time.Time `json:"updated"`
+}
+
+var csvHeader = []string{"name", "granularity", "period", "date", "value"}
+
+// endOfTime bounds the event ranges of an export
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Export writes every metric in the store to w and returns how many records
+// were written. JSON Lines also get the events of each metric's source and
+// the runs and checkpoint of each metric.
+func Export(s Store, w io.Writer, format Format) (int, error) {
+	names, err := s.ListMetricNames()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list metrics")
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	cw := csv.NewWriter(bw)
+	if format == CSV {
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	for _, name := range names {
+		for _, g := range statboard.Granularities {
+			metrics, err := s.GetMetric(name, g, time.Time{})
+			if err != nil {
+				return n, errors.Wrap(err, fmt.Sprintf("failed to get %q metrics", name))
+			}
+			for _, m := range metrics {
+				rec := metricRecord{Type: metricType, Name: m.Name, Granularity: g, Period: g.Period(m.Date), Date: m.Date.UTC(), Value: m.Value}
+				if format == CSV {
+					err = cw.Write([]string{rec.Name, string(rec.Granularity), rec.Period, rec.Date.Format("2006-01-02"), strconv.FormatFloat(rec.Value, 'f', -1, 64)})
+				} else {
+					err = enc.Encode(rec)
+				}
+				if err != nil {
+					return n, errors.Wrap(err, fmt.Sprintf("failed to write metric %q", m.ID))
+				}
+				n++
+			}
+		}
+	}
+
+	if format == CSV {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return n, err
+		}
+		return n, bw.Flush()
+	}
+
+	written, err := exportHistory(s, enc, names)
+	n += written
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// exportHistory encodes the events, runs and checkpoints behind the metrics
+// and returns how many records were written
+func exportHistory(s Store, enc *json.Encoder, names []string) (int, error) {
+	var n int
+	for _, source := range metricSources(names) {
+		events, err := s.GetEvents(source, time.Time{}, endOfTime)
+		if err != nil {
+			return n, errors.Wrap(err, fmt.Sprintf("failed to get %q events", source))
+		}
+		for _, e := range events {
+			rec := eventRecord{Type: eventType, ID: e.ID, Source: e.Source, Kind: e.Kind, Timestamp: e.Timestamp, Value: e.Value, Attributes: e.Attributes}
+			if err := enc.Encode(rec); err != nil {
+				return n, errors.Wrap(err, fmt.Sprintf("failed to write event %q", e.ID))
+			}
+			n++
+		}
+	}
+
+	for _, name := range names {
+		runs, err := s.GetRuns(name)
+		if err != nil {
+			return n, errors.Wrap(err, fmt.Sprintf("failed to get runs for %q", name))
+		}
+		for _, r := range runs {
+			rec := runRecord{Type: runType, Metric: r.Metric, Start: r.Start, End: r.End, Records: r.Records, Error: r.Error}
+			if err := enc.Encode(rec); err != nil {
+				return n, errors.Wrap(err, fmt.Sprintf("failed to write run %q", runID(r)))
+			}
+			n++
+		}
+
+		c, err := s.GetCheckpoint(name)
+		if err != nil {
+			return n, errors.Wrap(err, fmt.Sprintf("failed to get %q checkpoint", name))
+		}
+		if c.HighWaterMark.IsZero() {
+			continue
+		}
+		rec := checkpointRecord{Type: checkpointType, Metric: c.Metric, HighWaterMark: c.HighWaterMark, Updated: c.Updated}
+		if err := enc.Encode(rec); err != nil {
+			return n, errors.Wrap(err, fmt.Sprintf("failed to write checkpoint %q", c.Metric))
+		}
+		n++
+	}
+	return n, nil
+}
+
+// metricSources returns the sorted distinct sources of metrics named source.metric
+func metricSources(names []string) []string {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, name := range names {
+		source := strings.SplitN(name, ".", 2)[0]
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// records holds the records read from an export
+type records struct {
+	metrics     []statboard.Metric
+	events      []statboard.Event
+	runs        []statboard.Run
+	checkpoints []statboard.Checkpoint
+}
+
+// Import reads the records written by Export from r and stores them. Metrics
+// and events are each written in a single commit. Every record is keyed, so
+// importing the same file twice leaves the store unchanged. It returns how
+// many records were read.
+func Import(s Store, r io.Reader, format Format) (int, error) {
+	var recs records
+	var err error
+	if format == CSV {
+		recs.metrics, err = readCSV(r)
+	} else {
+		recs, err = readJSONLines(r)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.WriteMetrics(recs.metrics); err != nil {
+		return 0, errors.Wrap(err, "failed to write metrics")
+	}
+	if len(recs.events) > 0 {
+		if err := s.WriteEvents(recs.events); err != nil {
+			return 0, errors.Wrap(err, "failed to write events")
+		}
+	}
+	for _, run := range recs.runs {
+		if err := s.WriteRun(run); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to write run %q", runID(run)))
+		}
+	}
+	for _, c := range recs.checkpoints {
+		if err := s.WriteCheckpoint(c); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to write checkpoint %q", c.Metric))
+		}
+	}
+	return len(recs.metrics) + len(recs.events) + len(recs.runs) + len(recs.checkpoints), nil
+}
+
+func readJSONLines(r io.Reader) (records, error) {
+	var recs records
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return recs, nil
+			}
+			return records{}, errors.Wrap(err, fmt.Sprintf("record %d", line))
+		}
+		if err := recs.add(raw); err != nil {
+			return records{}, errors.Wrap(err, fmt.Sprintf("record %d", line))
+		}
+	}
+}
+
+// add decodes a JSON Lines record and appends it to the records of its type
+func (recs *records) add(raw json.RawMessage) error {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return err
+	}
+
+	switch typed.Type {
+	case "", metricType:
+		var rec metricRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		m, err := rec.metric()
+		if err != nil {
+			return err
+		}
+		recs.metrics = append(recs.metrics, m)
+	case eventType:
+		var rec eventRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		if rec.ID == "" || rec.Source == "" {
+			return errors.New("event id and source must be set")
+		}
+		recs.events = append(recs.events, statboard.Event{ID: rec.ID, Source: rec.Source, Kind: rec.Kind, Timestamp: rec.Timestamp, Value: rec.Value, Attributes: rec.Attributes})
+	case runType:
+		var rec runRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		if rec.Metric == "" {
+			return errors.New("run metric is empty")
+		}
+		recs.runs = append(recs.runs, statboard.Run{Metric: rec.Metric, Start: rec.Start, End: rec.End, Records: rec.Records, Error: rec.Error})
+	case checkpointType:
+		var rec checkpointRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		if rec.Metric == "" {
+			return errors.New("checkpoint metric is empty")
+		}
+		recs.checkpoints = append(recs.checkpoints, statboard.Checkpoint{Metric: rec.Metric, HighWaterMark: rec.HighWaterMark, Updated: rec.Updated})
+	default:
+		return fmt.Errorf("unsupported record type %q, valid types are: metric, event, run, checkpoint", typed.Type)
+	}
+	return nil
+}
+
+func readCSV(r io.Reader) ([]statboard.Metric, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv")
+	}
+
+	var metrics []statboard.Metric
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && row[0] == csvHeader[0] {
+			continue
+		}
+		if len(row) != len(csvHeader) {
+			return nil, fmt.Errorf("line %d: expected %d columns, got %d", i+1, len(csvHeader), len(row))
+		}
+		rec := metricRecord{Name: row[0], Granularity: statboard.Granularity(row[1]), Period: row[2]}
+		if rec.Date, err = time.Parse("2006-01-02", row[3]); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d: failed to parse date", i+1))
+		}
+		if rec.Value, err = strconv.ParseFloat(row[4], 64); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d: failed to parse value", i+1))
+		}
+		m, err := rec.metric()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d", i+1))
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// metric validates the record and returns the metric it holds. A hand-edited
+// date must still fall in the record's period.
+func (rec metricRecord) metric() (statboard.Metric, error) {
+	if rec.Name == "" {
+		return statboard.Metric{}, errors.New("metric name is empty")
+	}
+	g, err := statboard.ParseGranularity(string(rec.Granularity))
+	if err != nil {
+		return statboard.Metric{}, err
+	}
+	if rec.Period != "" && g.Period(rec.Date) != rec.Period {
+		return statboard.Metric{}, fmt.Errorf("date %s is not in period %s", rec.Date.Format("2006-01-02"), rec.Period)
+	}
+	return statboard.Metric{Name: rec.Name, Date: g.Truncate(rec.Date), Value: rec.Value, Granularity: g}, nil
+}
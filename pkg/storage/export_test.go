@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := []statboard.Metric{
+		{Name: "testMetric", Date: testTime, Value: 1.5},
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 2.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 0, 1), Value: 3.0, Granularity: statboard.Daily},
+		{Name: "otherMetric", Date: testTime, Value: 4.0, Granularity: statboard.Yearly},
+	}
+
+	for _, format := range []Format{JSONLines, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			src, err := NewStormStore("test.db")
+			assert.NoError(t, err)
+			defer os.Remove("test.db")
+			defer src.Close()
+			assert.NoError(t, src.WriteMetrics(metrics))
+
+			var buf bytes.Buffer
+			n, err := Export(src, &buf, format)
+			assert.NoError(t, err)
+			assert.Equal(t, 4, n)
+
+			dst, _, cleanup := newSQLiteTestStore(t)
+			defer cleanup()
+
+			// importing twice leaves a single record per period
+			for i := 0; i < 2; i++ {
+				n, err = Import(dst, bytes.NewReader(buf.Bytes()), format)
+				assert.NoError(t, err)
+				assert.Equal(t, 4, n)
+			}
+
+			actual, err := dst.GetMetric("testMetric", statboard.Monthly, time.Time{})
+			assert.NoError(t, err)
+			assert.Len(t, actual, 2)
+			assert.Equal(t, 1.5, actual[0].Value)
+
+			actual, err = dst.GetMetric("otherMetric", statboard.Yearly, time.Time{})
+			assert.NoError(t, err)
+			assert.Len(t, actual, 1)
+		})
+	}
+}
+
+func TestExportImport_History(t *testing.T) {
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	src, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+	defer os.Remove("test.db")
+	defer src.Close()
+	assert.NoError(t, src.WriteMetric(statboard.Metric{Name: "goodreads.books_read", Date: testTime, Value: 1.0}))
+	assert.NoError(t, src.WriteEvents([]statboard.Event{
+		{ID: "goodreads-1", Source: "goodreads", Kind: "read", Timestamp: testTime, Value: 1, Attributes: map[string]string{"pages": "100"}},
+		{ID: "github-1", Source: "github", Kind: "contribution", Timestamp: testTime, Value: 1},
+	}))
+	assert.NoError(t, src.WriteRun(statboard.Run{Metric: "goodreads.books_read", Start: testTime, End: testTime.Add(time.Minute), Records: 1}))
+	assert.NoError(t, src.WriteCheckpoint(statboard.Checkpoint{Metric: "goodreads.books_read", HighWaterMark: testTime, Updated: testTime}))
+
+	// csv only holds the metrics
+	var buf bytes.Buffer
+	n, err := Export(src, &buf, CSV)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// the github event has no stored metric, so its source is not exported
+	buf.Reset()
+	n, err = Export(src, &buf, JSONLines)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	dst, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+	n, err = Import(dst, bytes.NewReader(buf.Bytes()), JSONLines)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	events, err := dst.GetEvents("goodreads", testTime, testTime.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "goodreads-1", events[0].ID)
+	assert.Equal(t, map[string]string{"pages": "100"}, events[0].Attributes)
+
+	runs, err := dst.GetRuns("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, 1, runs[0].Records)
+
+	cp, err := dst.GetCheckpoint("goodreads.books_read")
+	assert.NoError(t, err)
+	assert.True(t, testTime.Equal(cp.HighWaterMark))
+}
+
+func TestImport_Invalid(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	// the date is not in the given period
+	_, err := Import(s, strings.NewReader("name,granularity,period,date,value\ntestMetric,month,2018-02,2018-01-01,3\n"), CSV)
+	assert.Error(t, err)
+
+	_, err = Import(s, strings.NewReader(`{"name":"testMetric","granularity":"hour","date":"2018-01-01T00:00:00Z","value":1}`), JSONLines)
+	assert.Error(t, err)
+
+	_, err = Import(s, strings.NewReader(`{"type":"note","name":"testMetric"}`), JSONLines)
+	assert.Error(t, err)
+
+	names, err := s.ListMetricNames()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/asdine/storm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// MigrateStorm copies every metric, event, run and checkpoint from the Storm
+// file at filePath into dst. Records already in dst are overwritten, so the
+// migration can be repeated.
+func MigrateStorm(filePath string, dst Store) error {
+	src, err := NewStormStore(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	db := src.(*stormStore).db
+
+	var metrics []statboard.Metric
+	if err := allRecords(db, &metrics); err != nil {
+		return errors.Wrap(err, "failed to read metrics")
+	}
+	// Legacy records of the same period are merged as Storm reads them, rather
+	// than left to whichever is upserted last
+	if err := dst.WriteMetrics(onePerPeriodByName(metrics)); err != nil {
+		return errors.Wrap(err, "failed to write metrics")
+	}
+
+	var events []statboard.Event
+	if err := allRecords(db.From(eventsBucket), &events); err != nil {
+		return errors.Wrap(err, "failed to read events")
+	}
+	if err := dst.WriteEvents(events); err != nil {
+		return errors.Wrap(err, "failed to write events")
+	}
+
+	var runs []statboard.Run
+	if err := allRecords(db, &runs); err != nil {
+		return errors.Wrap(err, "failed to read runs")
+	}
+	for _, r := range runs {
+		if err := dst.WriteRun(r); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to write run %q", r.ID))
+		}
+	}
+
+	var checkpoints []statboard.Checkpoint
+	if err := allRecords(db, &checkpoints); err != nil {
+		return errors.Wrap(err, "failed to read checkpoints")
+	}
+	for _, c := range checkpoints {
+		if err := dst.WriteCheckpoint(c); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to write checkpoint %q", c.Metric))
+		}
+	}
+
+	logrus.Info(fmt.Sprintf("migrated %d metrics, %d events, %d runs and %d checkpoints from %q",
+		len(metrics), len(events), len(runs), len(checkpoints), filePath))
+	return nil
+}
+
+// DedupeMetrics rewrites the metrics of a Storm store under their canonical
+// IDs in a single transaction, merging records of the same period written
+// under older ID schemes. It returns the number of records removed. The SQL
+// stores key metrics by period, and SQLite files written under older IDs are
+// rewritten when they are opened, so they have nothing to dedupe.
+func DedupeMetrics(s Store) (int, error) {
+	ss, ok := s.(*stormStore)
+	if !ok {
+		return 0, nil
+	}
+
+	tx, err := ss.db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var metrics []statboard.Metric
+	if err := allRecords(tx, &metrics); err != nil {
+		return 0, errors.Wrap(err, "failed to read metrics")
+	}
+	for i := range metrics {
+		if err := tx.DeleteStruct(&metrics[i]); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete metric %q", metrics[i].ID))
+		}
+	}
+
+	unique := onePerPeriodByName(metrics)
+	for i := range unique {
+		if err := tx.Save(&unique[i]); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to save metric %q", unique[i].ID))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	removed := len(metrics) - len(unique)
+	logrus.Info(fmt.Sprintf("rewrote %d metrics under canonical IDs, removed %d duplicates", len(unique), removed))
+	return removed, nil
+}
+
+// onePerPeriodByName returns the metrics with a single record per name,
+// granularity and period, ordered by name and date
+func onePerPeriodByName(metrics []statboard.Metric) []statboard.Metric {
+	byName := make(map[string][]statboard.Metric)
+	for _, m := range metrics {
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+
+	var unique []statboard.Metric
+	for _, name := range uniqueNames(metrics) {
+		unique = append(unique, onePerPeriod(byName[name])...)
+	}
+	return unique
+}
+
+// allRecords reads every record of a type from a storm node
+func allRecords(node storm.Node, to interface{}) error {
+	err := node.All(to)
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	return err
+}
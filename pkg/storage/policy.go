@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/sirupsen/logrus"
+)
+
+// WritePolicy decides which value is kept when a metric is written over a
+// period that is already stored
+type WritePolicy string
+
+const (
+	// Overwrite always stores the new value
+	Overwrite WritePolicy = "overwrite"
+	// KeepNonEmpty stores the new value unless it would replace a non-zero value with zero
+	KeepNonEmpty WritePolicy = "keep_non_empty"
+	// KeepMax stores the larger of the two values
+	KeepMax WritePolicy = "keep_max"
+)
+
+// ParseWritePolicy returns the WritePolicy for a config value. An empty value is KeepNonEmpty.
+func ParseWritePolicy(s string) (WritePolicy, error) {
+	switch p := WritePolicy(s); p {
+	case "":
+		return KeepNonEmpty, nil
+	case Overwrite, KeepNonEmpty, KeepMax:
+		return p, nil
+	}
+	return "", fmt.Errorf("unsupported write policy %q, valid policies are: overwrite, keep_non_empty, keep_max", s)
+}
+
+// resolve returns the value to store for a period holding old when new is written
+func (p WritePolicy) resolve(old float64, new float64) float64 {
+	switch p {
+	case KeepNonEmpty:
+		if new == 0 {
+			return old
+		}
+	case KeepMax:
+		if old > new {
+			return old
+		}
+	}
+	return new
+}
+
+// policyStore applies a WritePolicy to the metrics written to a Store
+type policyStore struct {
+	Store
+	policy WritePolicy
+}
+
+// WithWritePolicy returns a Store that writes metrics to s according to the policy.
+// Every stored value that changes, or is kept over a different new value, is logged.
+func WithWritePolicy(s Store, policy WritePolicy) Store {
+	if policy == Overwrite {
+		return s
+	}
+	return &policyStore{Store: s, policy: policy}
+}
+
+// WriteMetric writes m unless the policy keeps the stored value of its period
+func (s *policyStore) WriteMetric(m statboard.Metric) error {
+	return s.WriteMetrics([]statboard.Metric{m})
+}
+
+// WriteMetrics writes every metric the policy does not keep the stored value for
+func (s *policyStore) WriteMetrics(metrics []statboard.Metric) error {
+	stored, err := s.stored(metrics)
+	if err != nil {
+		return err
+	}
+
+	var write []statboard.Metric
+	for _, m := range metrics {
+		old, ok := stored[periodKey(m)]
+		if ok && old.Value != m.Value {
+			entry := logrus.WithFields(logrus.Fields{"metric": m.Name, "date": m.Date.Format("2006-01-02"), "old": old.Value, "new": m.Value})
+			if s.policy.resolve(old.Value, m.Value) == old.Value {
+				entry.Warn(fmt.Sprintf("keeping stored value under %s write policy", s.policy))
+				continue
+			}
+			entry.Info("metric value changed")
+		}
+		write = append(write, m)
+	}
+
+	return s.Store.WriteMetrics(write)
+}
+
+// metricPeriod identifies the period of a metric
+type metricPeriod struct {
+	name        string
+	granularity statboard.Granularity
+	date        int64
+}
+
+func periodKey(m statboard.Metric) metricPeriod {
+	return metricPeriod{name: m.Name, granularity: m.Granularity.OrDefault(), date: m.Date.Unix()}
+}
+
+// stored returns the stored metrics for the periods of metrics. Each metric
+// and granularity is read with a single range query over its dates.
+func (s *policyStore) stored(metrics []statboard.Metric) (map[metricPeriod]statboard.Metric, error) {
+	type series struct {
+		name        string
+		granularity statboard.Granularity
+	}
+	ranges := make(map[series][2]time.Time)
+	for _, m := range metrics {
+		k := series{m.Name, m.Granularity.OrDefault()}
+		r, ok := ranges[k]
+		if !ok || m.Date.Before(r[0]) {
+			r[0] = m.Date
+		}
+		if !ok || m.Date.After(r[1]) {
+			r[1] = m.Date
+		}
+		ranges[k] = r
+	}
+
+	stored := make(map[metricPeriod]statboard.Metric)
+	for k, r := range ranges {
+		existing, err := s.GetMetricRange(k.name, k.granularity, r[0], r[1].Add(time.Nanosecond))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range existing {
+			m.Granularity = k.granularity
+			stored[periodKey(m)] = m
+		}
+	}
+	return stored, nil
+}
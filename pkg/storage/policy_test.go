@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePolicyResolve(t *testing.T) {
+	tt := []struct {
+		policy   WritePolicy
+		old      float64
+		new      float64
+		expected float64
+	}{
+		{Overwrite, 5, 0, 0},
+		{KeepNonEmpty, 5, 0, 5},
+		{KeepNonEmpty, 5, 3, 3},
+		{KeepMax, 5, 3, 5},
+		{KeepMax, 5, 8, 8},
+	}
+
+	for _, ts := range tt {
+		t.Run(string(ts.policy), func(t *testing.T) {
+			assert.Equal(t, ts.expected, ts.policy.resolve(ts.old, ts.new))
+		})
+	}
+}
+
+func TestParseWritePolicy(t *testing.T) {
+	p, err := ParseWritePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, KeepNonEmpty, p)
+
+	p, err = ParseWritePolicy("keep_max")
+	assert.NoError(t, err)
+	assert.Equal(t, KeepMax, p)
+
+	_, err = ParseWritePolicy("newest")
+	assert.Error(t, err)
+}
+
+func TestWithWritePolicy(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	s := WithWritePolicy(b, KeepNonEmpty)
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: jan, Value: 4, Granularity: statboard.Monthly}))
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: feb, Value: 2, Granularity: statboard.Monthly}))
+
+	// an empty January is not stored, a changed February is
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: jan, Value: 0, Granularity: statboard.Monthly}))
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: feb, Value: 3, Granularity: statboard.Monthly}))
+
+	metrics, err := s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	for _, met := range metrics {
+		if met.Date.Equal(jan) {
+			assert.Equal(t, 4.0, met.Value)
+		} else {
+			assert.Equal(t, 3.0, met.Value)
+		}
+	}
+}
+
+// countingStore counts the range queries made against a Store
+type countingStore struct {
+	Store
+	rangeQueries int
+}
+
+func (s *countingStore) GetMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error) {
+	s.rangeQueries++
+	return s.Store.GetMetricRange(name, granularity, from, to)
+}
+
+func TestWithWritePolicy_Batch(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	var stored, written []statboard.Metric
+	for day := 0; day < 31; day++ {
+		stored = append(stored, statboard.Metric{Name: "testMetric", Date: jan.AddDate(0, 0, day), Value: 5, Granularity: statboard.Daily})
+		written = append(written, statboard.Metric{Name: "testMetric", Date: jan.AddDate(0, 0, day), Value: float64(day % 2 * 8), Granularity: statboard.Daily})
+	}
+	assert.NoError(t, b.WriteMetrics(stored))
+
+	cs := &countingStore{Store: b}
+	s := WithWritePolicy(cs, KeepMax)
+	assert.NoError(t, s.WriteMetrics(written))
+	assert.Equal(t, 1, cs.rangeQueries)
+
+	metrics, err := b.GetMetric("testMetric", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 31)
+	assert.Equal(t, 5.0, metrics[0].Value)
+	assert.Equal(t, 8.0, metrics[1].Value)
+}
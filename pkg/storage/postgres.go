@@ -0,0 +1,372 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	// registers the "postgres" database/sql driver
+	_ "github.com/lib/pq"
+)
+
+// postgresMigrations are applied in order and recorded in schema_migrations.
+// Released migrations must never change; add a new one instead.
+var postgresMigrations = []string{
+	// 1: initial schema
+	`CREATE TABLE metrics (
+		name        TEXT NOT NULL,
+		granularity TEXT NOT NULL,
+		period      TIMESTAMPTZ NOT NULL,
+		value       DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (name, granularity, period)
+	);
+	CREATE INDEX metrics_period ON metrics (period);
+
+	CREATE TABLE events (
+		id          TEXT PRIMARY KEY,
+		source      TEXT NOT NULL,
+		kind        TEXT NOT NULL,
+		occurred_at TIMESTAMPTZ NOT NULL,
+		zone_offset INTEGER NOT NULL,
+		value       DOUBLE PRECISION NOT NULL,
+		attributes  JSONB NOT NULL
+	);
+	CREATE INDEX events_source_occurred_at ON events (source, occurred_at);
+
+	CREATE TABLE runs (
+		metric     TEXT NOT NULL,
+		start_time TIMESTAMPTZ NOT NULL,
+		end_time   TIMESTAMPTZ NOT NULL,
+		records    INTEGER NOT NULL,
+		error      TEXT NOT NULL,
+		PRIMARY KEY (metric, start_time)
+	);
+
+	CREATE TABLE checkpoints (
+		metric          TEXT PRIMARY KEY,
+		high_water_mark TIMESTAMPTZ NOT NULL,
+		updated         TIMESTAMPTZ NOT NULL
+	);`,
+}
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new metric db instance backed by the Postgres
+// database at the given connection string and brings its schema up to date
+func NewPostgresStore(dsn string) (Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open postgres db")
+	}
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// migratePostgres applies every migration the database has not seen yet. The
+// table lock keeps instances started at the same time from racing.
+func migratePostgres(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied TIMESTAMPTZ NOT NULL)`); err != nil {
+		return errors.Wrap(err, "failed to create schema_migrations table")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return errors.Wrap(err, "failed to lock schema_migrations table")
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return errors.Wrap(err, "failed to get schema version")
+	}
+
+	for v := version + 1; v <= len(postgresMigrations); v++ {
+		if _, err := tx.Exec(postgresMigrations[v-1]); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to apply schema migration %d", v))
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied) VALUES ($1, $2)`, v, time.Now()); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to record schema migration %d", v))
+		}
+		logrus.Info(fmt.Sprintf("applied schema migration %d", v))
+	}
+	return tx.Commit()
+}
+
+// WriteMetric inserts or updates metric in database
+func (s *postgresStore) WriteMetric(m statboard.Metric) error {
+	return s.WriteMetrics([]statboard.Metric{m})
+}
+
+// WriteMetrics inserts or updates metrics in a single transaction
+func (s *postgresStore) WriteMetrics(metrics []statboard.Metric) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO metrics (name, granularity, period, value) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name, granularity, period) DO UPDATE SET value = excluded.value`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		m = normalizeMetric(m)
+		if _, err := stmt.Exec(m.Name, string(m.Granularity), m.Date, m.Value); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save metric %q", m.ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMetric returns the metrics of the given granularity since the given date ordered by date
+func (s *postgresStore) GetMetric(name string, granularity statboard.Granularity, since time.Time) ([]statboard.Metric, error) {
+	return s.queryMetrics(`SELECT name, granularity, period, value FROM metrics
+		WHERE name = $1 AND granularity = $2 AND period > $3 ORDER BY period`,
+		name, string(granularity.OrDefault()), since.UTC())
+}
+
+// GetMetricRange returns the metrics of the given granularity in the range ordered by date
+func (s *postgresStore) GetMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error) {
+	return s.queryMetrics(`SELECT name, granularity, period, value FROM metrics
+		WHERE name = $1 AND granularity = $2 AND period >= $3 AND period < $4 ORDER BY period`,
+		name, string(granularity.OrDefault()), from.UTC(), to.UTC())
+}
+
+// GetLatestMetric returns the metric of the latest stored period of a granularity
+func (s *postgresStore) GetLatestMetric(name string, granularity statboard.Granularity) (statboard.Metric, bool, error) {
+	metrics, err := s.queryMetrics(`SELECT name, granularity, period, value FROM metrics
+		WHERE name = $1 AND granularity = $2 ORDER BY period DESC LIMIT 1`,
+		name, string(granularity.OrDefault()))
+	if err != nil || len(metrics) == 0 {
+		return statboard.Metric{}, false, err
+	}
+	return metrics[0], true, nil
+}
+
+func (s *postgresStore) queryMetrics(query string, args ...interface{}) ([]statboard.Metric, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []statboard.Metric
+	for rows.Next() {
+		var m statboard.Metric
+		var g string
+		if err := rows.Scan(&m.Name, &g, &m.Date, &m.Value); err != nil {
+			return nil, err
+		}
+		m.Date = m.Date.UTC()
+		m.Granularity = statboard.Granularity(g)
+		m.ID = metricID(m)
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// ListMetricNames returns the sorted names of every stored metric
+func (s *postgresStore) ListMetricNames() ([]string, error) {
+	return queryNames(s.db, `SELECT DISTINCT name FROM metrics ORDER BY name`)
+}
+
+// DeleteMetric deletes every granularity of a metric in the range
+func (s *postgresStore) DeleteMetric(name string, from time.Time, to time.Time) (int, error) {
+	var res sql.Result
+	var err error
+	if to.IsZero() {
+		res, err = s.db.Exec(`DELETE FROM metrics WHERE name = $1 AND period >= $2`, name, from.UTC())
+	} else {
+		res, err = s.db.Exec(`DELETE FROM metrics WHERE name = $1 AND period >= $2 AND period < $3`, name, from.UTC(), to.UTC())
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteMetricRange deletes the metrics of a single granularity in the range
+func (s *postgresStore) DeleteMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM metrics WHERE name = $1 AND granularity = $2 AND period >= $3 AND period < $4`,
+		name, string(granularity.OrDefault()), from.UTC(), to.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// WriteEvents inserts or updates raw events in a single transaction
+func (s *postgresStore) WriteEvents(events []statboard.Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO events (id, source, kind, occurred_at, zone_offset, value, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET source = excluded.source, kind = excluded.kind, occurred_at = excluded.occurred_at,
+		zone_offset = excluded.zone_offset, value = excluded.value, attributes = excluded.attributes`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		attrs, err := json.Marshal(e.Attributes)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to marshal attributes of event %q", e.ID))
+		}
+		// keep the offset so events are aggregated by their local calendar date
+		_, offset := e.Timestamp.Zone()
+		if _, err := stmt.Exec(e.ID, e.Source, e.Kind, e.Timestamp.UTC(), offset, e.Value, string(attrs)); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save event %q", e.ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteEvents deletes the raw events with the given IDs in a single transaction
+func (s *postgresStore) DeleteEvents(ids []string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM events WHERE id = $1`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var n int64
+	for _, id := range ids {
+		res, err := stmt.Exec(id)
+		if err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete event %q", id))
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += deleted
+	}
+	return int(n), tx.Commit()
+}
+
+// GetEvents returns the raw events of a source from (inclusive) to (exclusive) ordered by time
+func (s *postgresStore) GetEvents(source string, from time.Time, to time.Time) ([]statboard.Event, error) {
+	rows, err := s.db.Query(`SELECT id, source, kind, occurred_at, zone_offset, value, attributes FROM events
+		WHERE source = $1 AND occurred_at >= $2 AND occurred_at < $3 ORDER BY occurred_at`,
+		source, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []statboard.Event
+	for rows.Next() {
+		var e statboard.Event
+		var attrs []byte
+		var offset int
+		if err := rows.Scan(&e.ID, &e.Source, &e.Kind, &e.Timestamp, &offset, &e.Value, &attrs); err != nil {
+			return nil, err
+		}
+		e.Timestamp = e.Timestamp.UTC()
+		if offset != 0 {
+			e.Timestamp = e.Timestamp.In(time.FixedZone("", offset))
+		}
+		if err := json.Unmarshal(attrs, &e.Attributes); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to unmarshal attributes of event %q", e.ID))
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// WriteRun inserts or updates a collection run in database
+func (s *postgresStore) WriteRun(r statboard.Run) error {
+	_, err := s.db.Exec(`INSERT INTO runs (metric, start_time, end_time, records, error) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (metric, start_time) DO UPDATE SET end_time = excluded.end_time, records = excluded.records, error = excluded.error`,
+		r.Metric, r.Start.UTC(), r.End.UTC(), r.Records, r.Error)
+	return err
+}
+
+// GetRuns returns all collection runs for a metric ordered by start time
+func (s *postgresStore) GetRuns(metric string) ([]statboard.Run, error) {
+	rows, err := s.db.Query(`SELECT metric, start_time, end_time, records, error FROM runs WHERE metric = $1 ORDER BY start_time`, metric)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []statboard.Run
+	for rows.Next() {
+		var r statboard.Run
+		if err := rows.Scan(&r.Metric, &r.Start, &r.End, &r.Records, &r.Error); err != nil {
+			return nil, err
+		}
+		r.ID = runID(r)
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// PruneRuns deletes all but the newest keep collection runs of a metric,
+// always keeping the latest successful run
+func (s *postgresStore) PruneRuns(metric string, keep int) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM runs WHERE metric = $1
+		AND start_time <= (SELECT start_time FROM runs WHERE metric = $1 ORDER BY start_time DESC LIMIT 1 OFFSET $2)
+		AND start_time NOT IN (SELECT start_time FROM runs WHERE metric = $1 AND error = '' ORDER BY start_time DESC LIMIT 1)`,
+		metric, keep)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// WriteCheckpoint inserts or updates the collection checkpoint of a metric
+func (s *postgresStore) WriteCheckpoint(c statboard.Checkpoint) error {
+	_, err := s.db.Exec(`INSERT INTO checkpoints (metric, high_water_mark, updated) VALUES ($1, $2, $3)
+		ON CONFLICT (metric) DO UPDATE SET high_water_mark = excluded.high_water_mark, updated = excluded.updated`,
+		c.Metric, c.HighWaterMark.UTC(), c.Updated.UTC())
+	return err
+}
+
+// GetCheckpoint returns the collection checkpoint of a metric. A metric that
+// has never been collected has an empty checkpoint.
+func (s *postgresStore) GetCheckpoint(metric string) (statboard.Checkpoint, error) {
+	c := statboard.Checkpoint{Metric: metric}
+	err := s.db.QueryRow(`SELECT high_water_mark, updated FROM checkpoints WHERE metric = $1`, metric).Scan(&c.HighWaterMark, &c.Updated)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c, nil
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// Close closes the database connection
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// snapshotter is implemented by stores that can write a consistent copy of
+// themselves while they are in use
+type snapshotter interface {
+	snapshotExt() string
+	writeSnapshot(path string) error
+	verifySnapshot(path string) error
+}
+
+// Snapshot writes a consistent copy of the store to a new file in dir and
+// checks that it opens cleanly. Only the newest keep snapshots are kept. It
+// returns the path of the new snapshot.
+func Snapshot(s Store, dir string, keep int) (string, error) {
+	snap, ok := s.(snapshotter)
+	if !ok {
+		return "", errors.New("store backend does not support snapshots, back it up with its own tools")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("failed to create snapshot dir %q", dir))
+	}
+
+	name := fmt.Sprintf("statboard-%s%s", time.Now().UTC().Format("20060102T150405Z"), snap.snapshotExt())
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+
+	if err := snap.writeSnapshot(tmp); err != nil {
+		os.Remove(tmp)
+		return "", errors.Wrap(err, "failed to write snapshot")
+	}
+	if err := snap.verifySnapshot(tmp); err != nil {
+		os.Remove(tmp)
+		return "", errors.Wrap(err, "snapshot failed verification")
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", errors.Wrap(err, "failed to rename snapshot")
+	}
+	logrus.Info(fmt.Sprintf("wrote snapshot %q", path))
+
+	return path, rotateSnapshots(dir, snap.snapshotExt(), keep)
+}
+
+// rotateSnapshots removes all but the newest keep snapshots in dir
+func rotateSnapshots(dir string, ext string, keep int) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "statboard-*"+ext))
+	if err != nil {
+		return err
+	}
+	// names sort by the time they were taken
+	sort.Strings(paths)
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to remove old snapshot %q", paths[0]))
+		}
+		logrus.Info(fmt.Sprintf("removed old snapshot %q", paths[0]))
+		paths = paths[1:]
+	}
+	return nil
+}
+
+func (s *stormStore) snapshotExt() string {
+	return ".db"
+}
+
+// writeSnapshot copies the database from a read transaction, so writers are not blocked
+func (s *stormStore) writeSnapshot(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = s.db.Bolt.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(f)
+		return err
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// verifySnapshot opens the snapshot read-only and checks every page is consistent
+func (s *stormStore) verifySnapshot(path string) error {
+	db, err := bolt.Open(path, 0644, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) snapshotExt() string {
+	return ".sqlite"
+}
+
+func (s *sqliteStore) writeSnapshot(path string) error {
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// verifySnapshot opens the snapshot read-only and runs an integrity check
+func (s *sqliteStore) verifySnapshot(path string) error {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+	defer b.Close()
+	defer os.Remove("test.db")
+
+	sqlite, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	tests := []struct {
+		name  string
+		store Store
+		open  func(string) (Store, error)
+	}{
+		{name: "storm", store: b, open: NewStormStore},
+		{name: "sqlite", store: sqlite, open: NewSQLiteStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "statboard")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			assert.NoError(t, tt.store.WriteMetric(statboard.Metric{Name: "testMetric", Date: testTime, Value: 5.0}))
+
+			path, err := Snapshot(tt.store, dir, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, dir, filepath.Dir(path))
+
+			// the snapshot is a complete database of its own
+			snap, err := tt.open(path)
+			assert.NoError(t, err)
+			defer snap.Close()
+			actual, err := snap.GetMetric("testMetric", statboard.Monthly, time.Time{})
+			assert.NoError(t, err)
+			assert.Len(t, actual, 1)
+			assert.Equal(t, 5.0, actual[0].Value)
+
+			tmp, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+			assert.NoError(t, err)
+			assert.Empty(t, tmp)
+		})
+	}
+}
+
+func TestSnapshot_Unsupported(t *testing.T) {
+	_, err := Snapshot(&postgresStore{}, "", 1)
+	assert.Error(t, err)
+}
+
+func TestRotateSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statboard")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"statboard-20180103T000000Z.db",
+		"statboard-20180101T000000Z.db",
+		"statboard-20180102T000000Z.db",
+		"statboard-20180101T000000Z.sqlite",
+		"other.db",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	assert.NoError(t, rotateSnapshots(dir, ".db", 2))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"other.db", "statboard-20180101T000000Z.sqlite", "statboard-20180102T000000Z.db", "statboard-20180103T000000Z.db"}, names)
+}
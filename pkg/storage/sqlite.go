@@ -0,0 +1,444 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	// registers the pure-Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// sqlTimeFormat stores times in UTC with a fixed width so they sort as text
+const sqlTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteMigrations are applied in order and recorded in schema_migrations.
+// Released migrations must never change; add a new one instead.
+var sqliteMigrations = []string{
+	// 1: initial schema, which files created before migrations were recorded already have
+	`CREATE TABLE IF NOT EXISTS metrics (
+		id          TEXT PRIMARY KEY,
+		name        TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		value       REAL NOT NULL,
+		granularity TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS metrics_name_date ON metrics (name, granularity, date);
+
+	CREATE TABLE IF NOT EXISTS events (
+		id          TEXT PRIMARY KEY,
+		source      TEXT NOT NULL,
+		kind        TEXT NOT NULL,
+		timestamp   TEXT NOT NULL,
+		zone_offset INTEGER NOT NULL,
+		value       REAL NOT NULL,
+		attributes  TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS events_source_timestamp ON events (source, timestamp);
+
+	CREATE TABLE IF NOT EXISTS runs (
+		id         TEXT PRIMARY KEY,
+		metric     TEXT NOT NULL,
+		start_time TEXT NOT NULL,
+		end_time   TEXT NOT NULL,
+		records    INTEGER NOT NULL,
+		error      TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS runs_metric_start ON runs (metric, start_time);
+
+	CREATE TABLE IF NOT EXISTS checkpoints (
+		metric          TEXT PRIMARY KEY,
+		high_water_mark TEXT NOT NULL,
+		updated         TEXT NOT NULL
+	);`,
+	// 2: key metrics by period. Metrics were keyed on an ID derived from the
+	// period, and older ID schemes left several rows for one period. The row
+	// under the canonical ID is kept, otherwise the largest value.
+	`CREATE TABLE metrics_by_period (
+		name        TEXT NOT NULL,
+		granularity TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		value       REAL NOT NULL,
+		PRIMARY KEY (name, granularity, date)
+	);
+	INSERT INTO metrics_by_period (name, granularity, date, value)
+		SELECT name, granularity, date, value FROM (
+			SELECT name, granularity, date, value, ROW_NUMBER() OVER (
+				PARTITION BY name, granularity, date
+				ORDER BY substr(id, 1, length(name) + length(granularity) + 2) = name || ':' || granularity || ':' DESC, value DESC
+			) AS preference
+			FROM metrics
+		) WHERE preference = 1;
+	DROP TABLE metrics;
+	ALTER TABLE metrics_by_period RENAME TO metrics;`,
+}
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates a new metric db instance backed by SQLite. The
+// database uses write-ahead logging so the reporter can read while the
+// collector writes from another process.
+func NewSQLiteStore(filePath string) (Store, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate", filePath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to open db with filepath %q", filePath))
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to migrate schema in db with filepath %q", filePath))
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// migrateSQLite applies every migration the database has not seen yet.
+// Transactions take the write lock up front, so stores opened at the same
+// time do not race.
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied TEXT NOT NULL)`); err != nil {
+		return errors.Wrap(err, "failed to create schema_migrations table")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return errors.Wrap(err, "failed to get schema version")
+	}
+
+	for v := version + 1; v <= len(sqliteMigrations); v++ {
+		if _, err := tx.Exec(sqliteMigrations[v-1]); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to apply schema migration %d", v))
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`, v, formatTime(time.Now())); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to record schema migration %d", v))
+		}
+		logrus.Info(fmt.Sprintf("applied schema migration %d", v))
+	}
+	return tx.Commit()
+}
+
+// WriteMetric inserts or updates metric in database
+func (s *sqliteStore) WriteMetric(m statboard.Metric) error {
+	return s.WriteMetrics([]statboard.Metric{m})
+}
+
+// WriteMetrics inserts or updates metrics in a single transaction
+func (s *sqliteStore) WriteMetrics(metrics []statboard.Metric) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO metrics (name, granularity, date, value) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name, granularity, date) DO UPDATE SET value = excluded.value`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		m = normalizeMetric(m)
+		if _, err := stmt.Exec(m.Name, string(m.Granularity), formatTime(m.Date), m.Value); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save metric %q", m.ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMetric returns the metrics of the given granularity since the given date ordered by date
+func (s *sqliteStore) GetMetric(name string, granularity statboard.Granularity, since time.Time) ([]statboard.Metric, error) {
+	return s.queryMetrics(`SELECT name, date, value, granularity FROM metrics
+		WHERE name = ? AND granularity = ? AND date > ? ORDER BY date`,
+		name, string(granularity.OrDefault()), formatTime(since))
+}
+
+// GetMetricRange returns the metrics of the given granularity in the range ordered by date
+func (s *sqliteStore) GetMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error) {
+	return s.queryMetrics(`SELECT name, date, value, granularity FROM metrics
+		WHERE name = ? AND granularity = ? AND date >= ? AND date < ? ORDER BY date`,
+		name, string(granularity.OrDefault()), formatTime(from), formatTime(to))
+}
+
+// GetLatestMetric returns the metric of the latest stored period of a granularity
+func (s *sqliteStore) GetLatestMetric(name string, granularity statboard.Granularity) (statboard.Metric, bool, error) {
+	metrics, err := s.queryMetrics(`SELECT name, date, value, granularity FROM metrics
+		WHERE name = ? AND granularity = ? ORDER BY date DESC LIMIT 1`,
+		name, string(granularity.OrDefault()))
+	if err != nil || len(metrics) == 0 {
+		return statboard.Metric{}, false, err
+	}
+	return metrics[0], true, nil
+}
+
+func (s *sqliteStore) queryMetrics(query string, args ...interface{}) ([]statboard.Metric, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []statboard.Metric
+	for rows.Next() {
+		var m statboard.Metric
+		var date, g string
+		if err := rows.Scan(&m.Name, &date, &m.Value, &g); err != nil {
+			return nil, err
+		}
+		if m.Date, err = parseTime(date); err != nil {
+			return nil, err
+		}
+		m.Granularity = statboard.Granularity(g)
+		m.ID = metricID(m)
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// ListMetricNames returns the sorted names of every stored metric
+func (s *sqliteStore) ListMetricNames() ([]string, error) {
+	return queryNames(s.db, `SELECT DISTINCT name FROM metrics ORDER BY name`)
+}
+
+// DeleteMetric deletes every granularity of a metric in the range
+func (s *sqliteStore) DeleteMetric(name string, from time.Time, to time.Time) (int, error) {
+	var res sql.Result
+	var err error
+	if to.IsZero() {
+		res, err = s.db.Exec(`DELETE FROM metrics WHERE name = ? AND date >= ?`, name, formatTime(from))
+	} else {
+		res, err = s.db.Exec(`DELETE FROM metrics WHERE name = ? AND date >= ? AND date < ?`, name, formatTime(from), formatTime(to))
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteMetricRange deletes the metrics of a single granularity in the range
+func (s *sqliteStore) DeleteMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM metrics WHERE name = ? AND granularity = ? AND date >= ? AND date < ?`,
+		name, string(granularity.OrDefault()), formatTime(from), formatTime(to))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// WriteEvents inserts or updates raw events in a single transaction
+func (s *sqliteStore) WriteEvents(events []statboard.Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO events (id, source, kind, timestamp, zone_offset, value, attributes) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET source = excluded.source, kind = excluded.kind, timestamp = excluded.timestamp,
+		zone_offset = excluded.zone_offset, value = excluded.value, attributes = excluded.attributes`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		attrs, err := json.Marshal(e.Attributes)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to marshal attributes of event %q", e.ID))
+		}
+		// keep the offset so events are aggregated by their local calendar date
+		_, offset := e.Timestamp.Zone()
+		if _, err := stmt.Exec(e.ID, e.Source, e.Kind, formatTime(e.Timestamp), offset, e.Value, string(attrs)); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save event %q", e.ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteEvents deletes the raw events with the given IDs in a single transaction
+func (s *sqliteStore) DeleteEvents(ids []string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM events WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var n int64
+	for _, id := range ids {
+		res, err := stmt.Exec(id)
+		if err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete event %q", id))
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += deleted
+	}
+	return int(n), tx.Commit()
+}
+
+// GetEvents returns the raw events of a source from (inclusive) to (exclusive) ordered by time
+func (s *sqliteStore) GetEvents(source string, from time.Time, to time.Time) ([]statboard.Event, error) {
+	rows, err := s.db.Query(`SELECT id, source, kind, timestamp, zone_offset, value, attributes FROM events
+		WHERE source = ? AND timestamp >= ? AND timestamp < ? ORDER BY timestamp`,
+		source, formatTime(from), formatTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []statboard.Event
+	for rows.Next() {
+		var e statboard.Event
+		var timestamp, attrs string
+		var offset int
+		if err := rows.Scan(&e.ID, &e.Source, &e.Kind, &timestamp, &offset, &e.Value, &attrs); err != nil {
+			return nil, err
+		}
+		if e.Timestamp, err = parseTime(timestamp); err != nil {
+			return nil, err
+		}
+		if offset != 0 {
+			e.Timestamp = e.Timestamp.In(time.FixedZone("", offset))
+		}
+		if err := json.Unmarshal([]byte(attrs), &e.Attributes); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to unmarshal attributes of event %q", e.ID))
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// WriteRun inserts or updates a collection run in database
+func (s *sqliteStore) WriteRun(r statboard.Run) error {
+	_, err := s.db.Exec(`INSERT INTO runs (id, metric, start_time, end_time, records, error) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET end_time = excluded.end_time, records = excluded.records, error = excluded.error`,
+		runID(r), r.Metric, formatTime(r.Start), formatTime(r.End), r.Records, r.Error)
+	return err
+}
+
+// GetRuns returns all collection runs for a metric ordered by start time
+func (s *sqliteStore) GetRuns(metric string) ([]statboard.Run, error) {
+	rows, err := s.db.Query(`SELECT id, metric, start_time, end_time, records, error FROM runs WHERE metric = ? ORDER BY start_time`, metric)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []statboard.Run
+	for rows.Next() {
+		var r statboard.Run
+		var start, end string
+		if err := rows.Scan(&r.ID, &r.Metric, &start, &end, &r.Records, &r.Error); err != nil {
+			return nil, err
+		}
+		if r.Start, err = parseTime(start); err != nil {
+			return nil, err
+		}
+		if r.End, err = parseTime(end); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// PruneRuns deletes all but the newest keep collection runs of a metric,
+// always keeping the latest successful run
+func (s *sqliteStore) PruneRuns(metric string, keep int) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM runs WHERE metric = ?
+		AND start_time <= (SELECT start_time FROM runs WHERE metric = ? ORDER BY start_time DESC LIMIT 1 OFFSET ?)
+		AND start_time NOT IN (SELECT start_time FROM runs WHERE metric = ? AND error = '' ORDER BY start_time DESC LIMIT 1)`,
+		metric, metric, keep, metric)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// WriteCheckpoint inserts or updates the collection checkpoint of a metric
+func (s *sqliteStore) WriteCheckpoint(c statboard.Checkpoint) error {
+	_, err := s.db.Exec(`INSERT INTO checkpoints (metric, high_water_mark, updated) VALUES (?, ?, ?)
+		ON CONFLICT (metric) DO UPDATE SET high_water_mark = excluded.high_water_mark, updated = excluded.updated`,
+		c.Metric, formatTime(c.HighWaterMark), formatTime(c.Updated))
+	return err
+}
+
+// GetCheckpoint returns the collection checkpoint of a metric. A metric that
+// has never been collected has an empty checkpoint.
+func (s *sqliteStore) GetCheckpoint(metric string) (statboard.Checkpoint, error) {
+	c := statboard.Checkpoint{Metric: metric}
+	var mark, updated string
+	err := s.db.QueryRow(`SELECT high_water_mark, updated FROM checkpoints WHERE metric = ?`, metric).Scan(&mark, &updated)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c, nil
+		}
+		return c, err
+	}
+	if c.HighWaterMark, err = parseTime(mark); err != nil {
+		return c, err
+	}
+	if c.Updated, err = parseTime(updated); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// Close closes the database connection
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// queryNames returns the single text column of every row of the query
+func queryNames(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeFormat)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(sqlTimeFormat, s)
+	if err != nil {
+		return t, errors.Wrap(err, fmt.Sprintf("failed to parse time %q", s))
+	}
+	return t, nil
+}
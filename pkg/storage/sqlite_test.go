@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajbosco/statboard/pkg/statboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLiteTestStore(t *testing.T) (Store, string, func()) {
+	dir, err := ioutil.TempDir("", "statboard")
+	assert.NoError(t, err)
+
+	s, err := NewSQLiteStore(filepath.Join(dir, "test.sqlite"))
+	assert.NoError(t, err)
+
+	return s, dir, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLiteGetMetric(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := []statboard.Metric{
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 28.0},
+		{Name: "testMetric", Date: testTime, Value: 30.0},
+		{Name: "testMetric", Date: testTime, Value: 31.0},
+		{Name: "testMetric", Date: testTime, Value: 1.0, Granularity: statboard.Daily},
+		{Name: "otherMetric", Date: testTime, Value: 5.0},
+	}
+	for _, m := range metrics {
+		assert.NoError(t, s.WriteMetric(m))
+	}
+
+	actual, err := s.GetMetric("testMetric", statboard.Monthly, testTime.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.Equal(t, testTime, actual[0].Date)
+	assert.Equal(t, 31.0, actual[0].Value)
+	assert.Equal(t, 28.0, actual[1].Value)
+
+	actual, err = s.GetMetric("testMetric", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, 1.0, actual[0].Value)
+
+	actual, err = s.GetMetric("missingMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestSQLiteGetLatestMetric(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	_, ok, err := s.GetLatestMetric("testMetric", statboard.Monthly)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.WriteMetrics([]statboard.Metric{
+		{Name: "testMetric", Date: testTime.AddDate(0, 2, 0), Value: 3.0},
+		{Name: "testMetric", Date: testTime, Value: 1.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 3, 0), Value: 1.0, Granularity: statboard.Daily},
+	}))
+
+	latest, ok, err := s.GetLatestMetric("testMetric", statboard.Monthly)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, testTime.AddDate(0, 2, 0).Equal(latest.Date))
+	assert.Equal(t, 3.0, latest.Value)
+}
+
+func TestSQLiteMigratesLegacyMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statboard")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "test.sqlite")
+
+	// a file created before metrics were keyed by period
+	db, err := sql.Open("sqlite", filePath)
+	assert.NoError(t, err)
+	_, err = db.Exec(sqliteMigrations[0])
+	assert.NoError(t, err)
+	jan := formatTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	feb := formatTime(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC))
+	for _, row := range []struct {
+		id    string
+		date  string
+		value float64
+	}{
+		{"testMetric-2018-01-01 00:00:00 +0000 UTC", jan, 9.0},
+		{"testMetric:month:2018-01", jan, 4.0},
+		{"testMetric-2018-02-01 00:00:00 +0000 UTC", feb, 2.0},
+		{"testMetric-2018-02-01 00:00:00 +0000 +0000", feb, 3.0},
+	} {
+		_, err = db.Exec(`INSERT INTO metrics (id, name, date, value, granularity) VALUES (?, 'testMetric', ?, ?, 'month')`, row.id, row.date, row.value)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, db.Close())
+
+	s, err := NewSQLiteStore(filePath)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// the canonical row wins, otherwise the largest legacy value
+	actual, err := s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.Equal(t, 4.0, actual[0].Value)
+	assert.Equal(t, 3.0, actual[1].Value)
+
+	// later writes replace the single row of the period
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 1.0}))
+	actual, err = s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.Equal(t, 1.0, actual[0].Value)
+
+	// opening the file again applies nothing twice
+	reopened, err := NewSQLiteStore(filePath)
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Close())
+}
+
+func TestSQLiteGetEvents(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	// read late in the evening of January 1st on the US west coast
+	pacific := time.Date(2018, 1, 1, 22, 0, 0, 0, time.FixedZone("", -8*60*60))
+
+	events := []statboard.Event{
+		{ID: "b", Source: "testSource", Kind: "testKind", Timestamp: testTime.AddDate(0, 0, 1), Value: 2.0},
+		{ID: "a", Source: "testSource", Kind: "testKind", Timestamp: pacific, Value: 1.0, Attributes: map[string]string{"key": "value"}},
+		{ID: "c", Source: "testSource", Kind: "testKind", Timestamp: testTime.AddDate(0, 1, 0), Value: 3.0},
+		{ID: "d", Source: "otherSource", Kind: "testKind", Timestamp: testTime, Value: 4.0},
+	}
+	assert.NoError(t, s.WriteEvents(events))
+
+	actual, err := s.GetEvents("testSource", testTime, testTime.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	// events are ordered by instant but keep their local calendar date
+	assert.Len(t, actual, 2)
+	assert.Equal(t, "b", actual[0].ID)
+	assert.Equal(t, "a", actual[1].ID)
+	assert.Equal(t, map[string]string{"key": "value"}, actual[1].Attributes)
+	assert.Equal(t, 1, actual[1].Timestamp.Day())
+	assert.True(t, pacific.Equal(actual[1].Timestamp))
+}
+
+func TestSQLiteDeleteEvents(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.WriteEvents([]statboard.Event{
+		{ID: "a", Source: "testSource", Timestamp: testTime, Value: 1.0},
+		{ID: "b", Source: "testSource", Timestamp: testTime, Value: 2.0},
+	}))
+
+	n, err := s.DeleteEvents([]string{"a", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	actual, err := s.GetEvents("testSource", testTime, testTime.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, "b", actual[0].ID)
+}
+
+func TestSQLiteGetRuns(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := []statboard.Run{
+		{Metric: "testMetric", Start: testTime.Add(time.Hour), End: testTime.Add(2 * time.Hour), Error: "failed"},
+		{Metric: "testMetric", Start: testTime, End: testTime.Add(time.Minute), Records: 3},
+		{Metric: "otherMetric", Start: testTime, End: testTime.Add(time.Minute), Records: 1},
+	}
+	for _, r := range runs {
+		assert.NoError(t, s.WriteRun(r))
+	}
+
+	actual, err := s.GetRuns("testMetric")
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.True(t, actual[0].Start.Equal(testTime))
+	assert.Equal(t, 3, actual[0].Records)
+	assert.True(t, actual[0].Succeeded())
+	assert.False(t, actual[1].Succeeded())
+}
+
+func TestSQLitePruneRuns(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := []statboard.Run{
+		{Metric: "testMetric", Start: testTime, End: testTime.Add(time.Minute)},
+		{Metric: "testMetric", Start: testTime.Add(time.Hour), End: testTime.Add(time.Hour + time.Minute)},
+		{Metric: "testMetric", Start: testTime.Add(2 * time.Hour), End: testTime.Add(2*time.Hour + time.Minute), Error: "failed"},
+		{Metric: "testMetric", Start: testTime.Add(3 * time.Hour), End: testTime.Add(3*time.Hour + time.Minute), Error: "failed"},
+		{Metric: "otherMetric", Start: testTime, End: testTime.Add(time.Minute)},
+	}
+	for _, r := range runs {
+		assert.NoError(t, s.WriteRun(r))
+	}
+
+	pruned, err := s.PruneRuns("testMetric", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	actual, err := s.GetRuns("testMetric")
+	assert.NoError(t, err)
+	assert.Len(t, actual, 3)
+	assert.True(t, actual[0].Start.Equal(testTime.Add(time.Hour)))
+
+	pruned, err = s.PruneRuns("otherMetric", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pruned)
+}
+
+func TestSQLiteGetCheckpoint(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	cp, err := s.GetCheckpoint("testMetric")
+	assert.NoError(t, err)
+	assert.True(t, cp.HighWaterMark.IsZero())
+
+	mark := time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.WriteCheckpoint(statboard.Checkpoint{Metric: "testMetric", HighWaterMark: mark}))
+
+	cp, err = s.GetCheckpoint("testMetric")
+	assert.NoError(t, err)
+	assert.True(t, mark.Equal(cp.HighWaterMark))
+}
+
+func TestSQLiteConcurrentStores(t *testing.T) {
+	s, dir, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	// a second handle on the same file, as the reporter would hold
+	reader, err := NewSQLiteStore(filepath.Join(dir, "test.sqlite"))
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.WriteMetric(statboard.Metric{Name: "testMetric", Date: testTime, Value: 3.0}))
+
+	metrics, err := reader.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+}
+
+func TestMigrateStorm(t *testing.T) {
+	s, dir, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	stormPath := filepath.Join(dir, "test.db")
+	src, err := NewStormStore(stormPath)
+	assert.NoError(t, err)
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, src.WriteMetric(statboard.Metric{Name: "testMetric", Date: testTime, Value: 3.0}))
+	assert.NoError(t, src.WriteEvents([]statboard.Event{{ID: "a", Source: "testSource", Timestamp: testTime, Value: 1.0}}))
+	assert.NoError(t, src.WriteRun(statboard.Run{Metric: "testMetric", Start: testTime, End: testTime, Records: 1}))
+	assert.NoError(t, src.WriteCheckpoint(statboard.Checkpoint{Metric: "testMetric", HighWaterMark: testTime}))
+	assert.NoError(t, src.Close())
+
+	assert.NoError(t, MigrateStorm(stormPath, s))
+
+	metrics, err := s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 3.0, metrics[0].Value)
+
+	events, err := s.GetEvents("testSource", time.Time{}, testTime.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	runs, err := s.GetRuns("testMetric")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	cp, err := s.GetCheckpoint("testMetric")
+	assert.NoError(t, err)
+	assert.True(t, testTime.Equal(cp.HighWaterMark))
+}
+
+func TestMigrateStorm_LegacyDuplicates(t *testing.T) {
+	s, dir, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	stormPath := filepath.Join(dir, "test.db")
+	src, err := NewStormStore(stormPath)
+	assert.NoError(t, err)
+
+	// records of the same months written under the old time.Time String IDs
+	eastern := time.FixedZone("EST", -5*60*60)
+	legacy := []statboard.Metric{
+		{Name: "testMetric", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Value: 50.0},
+		{Name: "testMetric", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, eastern), Value: 3.0},
+		{Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 9.0},
+	}
+	db := src.(*stormStore).db
+	for _, m := range legacy {
+		m.ID = fmt.Sprintf("%s-%s", m.Date, m.Name)
+		assert.NoError(t, db.Save(&m))
+	}
+	// March was corrected to a lower value under the canonical ID since
+	assert.NoError(t, src.WriteMetric(statboard.Metric{Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 2.0}))
+	expected, err := src.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.NoError(t, src.Close())
+
+	assert.NoError(t, MigrateStorm(stormPath, s))
+
+	metrics, err := s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, 50.0, metrics[0].Value)
+	assert.Equal(t, 2.0, metrics[1].Value)
+	for i := range expected {
+		assert.Equal(t, expected[i].Value, metrics[i].Value)
+	}
+}
+
+func TestSQLiteMetricRangeAndDelete(t *testing.T) {
+	s, _, cleanup := newSQLiteTestStore(t)
+	defer cleanup()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := s.WriteMetrics([]statboard.Metric{
+		{Name: "testMetric", Date: testTime, Value: 1.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 2.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 2, 0), Value: 3.0},
+		{Name: "otherMetric", Date: testTime, Value: 4.0},
+	})
+	assert.NoError(t, err)
+
+	metrics, err := s.GetMetricRange("testMetric", statboard.Monthly, testTime, testTime.AddDate(0, 2, 0))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, 2.0, metrics[1].Value)
+
+	names, err := s.ListMetricNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"otherMetric", "testMetric"}, names)
+
+	n, err := s.DeleteMetric("testMetric", testTime.AddDate(0, 1, 0), testTime.AddDate(0, 2, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	metrics, err = s.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+
+	n, err = s.DeleteMetricRange("testMetric", statboard.Daily, time.Time{}, testTime.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = s.DeleteMetricRange("testMetric", statboard.Monthly, time.Time{}, testTime.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
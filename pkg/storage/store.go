@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/ajbosco/statboard/pkg/statboard"
@@ -11,13 +12,67 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// Store represents the store that writes and reads metrics
+// Store represents the store that writes and reads metrics.
+// Ranges include from and exclude to.
 type Store interface {
-	GetMetric(name string, since time.Time) ([]statboard.Metric, error)
+	GetMetric(name string, granularity statboard.Granularity, since time.Time) ([]statboard.Metric, error)
+	GetMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error)
+	// GetLatestMetric returns the metric of the latest stored period of a
+	// granularity. ok is false if none is stored.
+	GetLatestMetric(name string, granularity statboard.Granularity) (m statboard.Metric, ok bool, err error)
+	ListMetricNames() ([]string, error)
 	WriteMetric(m statboard.Metric) error
+	WriteMetrics(metrics []statboard.Metric) error
+	// DeleteMetric deletes every granularity of a metric in the range and
+	// returns how many were deleted. A zero to has no upper bound.
+	DeleteMetric(name string, from time.Time, to time.Time) (int, error)
+	// DeleteMetricRange deletes the metrics of a single granularity from
+	// (inclusive) to (exclusive) and returns how many were deleted
+	DeleteMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) (int, error)
+	GetEvents(source string, from time.Time, to time.Time) ([]statboard.Event, error)
+	WriteEvents(events []statboard.Event) error
+	// DeleteEvents deletes the raw events with the given IDs and returns how
+	// many were deleted. Unknown IDs are ignored.
+	DeleteEvents(ids []string) (int, error)
+	GetRuns(metric string) ([]statboard.Run, error)
+	WriteRun(r statboard.Run) error
+	// PruneRuns deletes all but the newest keep collection runs of a metric
+	// and returns how many were deleted. The latest successful run is always kept.
+	PruneRuns(metric string, keep int) (int, error)
+	GetCheckpoint(metric string) (statboard.Checkpoint, error)
+	WriteCheckpoint(c statboard.Checkpoint) error
 	Close() error
 }
 
+// Backend names a Store implementation
+type Backend string
+
+const (
+	// Storm stores metrics in a BoltDB file. Only one process can open it at a time.
+	Storm Backend = "storm"
+	// SQLite stores metrics in a SQLite file that can be shared across processes
+	SQLite Backend = "sqlite"
+	// Postgres stores metrics in a Postgres database that can be shared across hosts
+	Postgres Backend = "postgres"
+)
+
+// Open creates the Store for the configured backend. An empty backend is Storm.
+// File backends use filePath and Postgres connects with dsn.
+func Open(backend string, filePath string, dsn string) (Store, error) {
+	switch Backend(backend) {
+	case "", Storm:
+		return NewStormStore(filePath)
+	case SQLite:
+		return NewSQLiteStore(filePath)
+	case Postgres:
+		if dsn == "" {
+			return nil, errors.New("'store.dsn' must be present in config for the postgres backend")
+		}
+		return NewPostgresStore(dsn)
+	}
+	return nil, fmt.Errorf("unsupported store backend %q, valid backends are: storm, sqlite, postgres", backend)
+}
+
 type stormStore struct {
 	db *storm.DB
 }
@@ -33,22 +88,325 @@ func NewStormStore(filePath string) (Store, error) {
 
 // WriteMetric inserts or updates metric in database
 func (s *stormStore) WriteMetric(m statboard.Metric) error {
-	m.ID = fmt.Sprintf("%s-%s", m.Date, m.Name)
+	m = normalizeMetric(m)
 	return s.db.Save(&m)
 }
 
-// GetMetric returns the metrics since the given date
-func (s *stormStore) GetMetric(name string, since time.Time) ([]statboard.Metric, error) {
+// metricID returns the ID a metric is stored under, made of its name,
+// granularity and ISO period such as "github.contributions:month:2018-03"
+func metricID(m statboard.Metric) string {
+	g := m.Granularity.OrDefault()
+	return fmt.Sprintf("%s:%s:%s", m.Name, g, g.Period(m.Date))
+}
+
+// normalizeMetric returns m with its date at the start of its period in UTC
+// and its canonical ID, so the same period is always stored as one record
+func normalizeMetric(m statboard.Metric) statboard.Metric {
+	m.Granularity = m.Granularity.OrDefault()
+	m.Date = m.Granularity.Truncate(m.Date)
+	m.ID = metricID(m)
+	return m
+}
+
+// GetMetric returns the metrics of the given granularity since the given date
+func (s *stormStore) GetMetric(name string, granularity statboard.Granularity, since time.Time) ([]statboard.Metric, error) {
+	var metrics []statboard.Metric
+	err := s.db.Select(q.And(q.Eq("Name", name), granularityMatcher(granularity), q.Gt("Date", since))).Find(&metrics)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return metrics, nil
+		}
+		return nil, err
+	}
+	logrus.Debug(fmt.Sprintf("found %d %q records", len(metrics), name))
+	return onePerPeriod(metrics), nil
+}
+
+// GetMetricRange returns the metrics of the given granularity in the range ordered by date
+func (s *stormStore) GetMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) ([]statboard.Metric, error) {
 	var metrics []statboard.Metric
-	err := s.db.Select(q.And(q.Eq("Name", name), q.Gt("Date", since))).Find(&metrics)
+	query := s.db.Select(q.Eq("Name", name), granularityMatcher(granularity), q.Gte("Date", from), q.Lt("Date", to))
+	err := query.Find(&metrics)
 	if err != nil {
 		if err == storm.ErrNotFound {
 			return metrics, nil
 		}
 		return nil, err
 	}
-	logrus.Info(fmt.Sprintf("found %d %q records", len(metrics), name))
-	return metrics, nil
+	return onePerPeriod(metrics), nil
+}
+
+// GetLatestMetric returns the metric of the latest stored period of a granularity
+func (s *stormStore) GetLatestMetric(name string, granularity statboard.Granularity) (statboard.Metric, bool, error) {
+	// Only the records of the latest period are kept while scanning, so the
+	// whole history is never decoded into memory at once
+	var latest []statboard.Metric
+	var latestDate time.Time
+	query := s.db.Select(q.Eq("Name", name), granularityMatcher(granularity))
+	err := query.Each(new(statboard.Metric), func(record interface{}) error {
+		m := *record.(*statboard.Metric)
+		date := m.Granularity.OrDefault().Truncate(m.Date)
+		if date.Before(latestDate) {
+			return nil
+		}
+		if date.After(latestDate) {
+			latest, latestDate = nil, date
+		}
+		latest = append(latest, m)
+		return nil
+	})
+	if err != nil {
+		return statboard.Metric{}, false, err
+	}
+	if len(latest) == 0 {
+		return statboard.Metric{}, false, nil
+	}
+	return onePerPeriod(latest)[0], true, nil
+}
+
+// onePerPeriod returns the metrics ordered by date with a single record per
+// period. Databases written before IDs were canonical can hold several
+// records of a period. Every write since is stored under the canonical ID, so
+// that record is kept, and otherwise the largest of the older values.
+func onePerPeriod(metrics []statboard.Metric) []statboard.Metric {
+	byID := make(map[string]statboard.Metric)
+	canonical := make(map[string]bool)
+	for _, m := range metrics {
+		isCanonical := m.ID == metricID(m)
+		m = normalizeMetric(m)
+		if dup, ok := byID[m.ID]; ok && (canonical[m.ID] || !isCanonical && dup.Value >= m.Value) {
+			continue
+		}
+		byID[m.ID] = m
+		canonical[m.ID] = isCanonical
+	}
+
+	var unique []statboard.Metric
+	for _, m := range byID {
+		unique = append(unique, m)
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].Date.Before(unique[j].Date) })
+	return unique
+}
+
+// ListMetricNames returns the sorted names of every stored metric
+func (s *stormStore) ListMetricNames() ([]string, error) {
+	var metrics []statboard.Metric
+	if err := s.db.All(&metrics); err != nil {
+		return nil, err
+	}
+	return uniqueNames(metrics), nil
+}
+
+// WriteMetrics inserts or updates metrics in a single transaction
+func (s *stormStore) WriteMetrics(metrics []statboard.Metric) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range metrics {
+		m = normalizeMetric(m)
+		if err := tx.Save(&m); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save metric %q", m.ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteMetric deletes every granularity of a metric in the range
+func (s *stormStore) DeleteMetric(name string, from time.Time, to time.Time) (int, error) {
+	matchers := []q.Matcher{q.Eq("Name", name), q.Gte("Date", from)}
+	if !to.IsZero() {
+		matchers = append(matchers, q.Lt("Date", to))
+	}
+	return s.deleteMetrics(matchers...)
+}
+
+// DeleteMetricRange deletes the metrics of a single granularity in the range
+func (s *stormStore) DeleteMetricRange(name string, granularity statboard.Granularity, from time.Time, to time.Time) (int, error) {
+	return s.deleteMetrics(q.Eq("Name", name), granularityMatcher(granularity), q.Gte("Date", from), q.Lt("Date", to))
+}
+
+// deleteMetrics deletes every metric matching all matchers in a single transaction
+func (s *stormStore) deleteMetrics(matchers ...q.Matcher) (int, error) {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var metrics []statboard.Metric
+	if err := tx.Select(matchers...).Find(&metrics); err != nil {
+		if err == storm.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	for i := range metrics {
+		if err := tx.DeleteStruct(&metrics[i]); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete metric %q", metrics[i].ID))
+		}
+	}
+	return len(metrics), tx.Commit()
+}
+
+// uniqueNames returns the sorted distinct names of the metrics
+func uniqueNames(metrics []statboard.Metric) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range metrics {
+		if !seen[m.Name] {
+			seen[m.Name] = true
+			names = append(names, m.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// granularityMatcher matches metrics of the given granularity. Metrics written
+// before granularities were introduced have none and are monthly.
+func granularityMatcher(granularity statboard.Granularity) q.Matcher {
+	if granularity.OrDefault() == statboard.Monthly {
+		return q.Or(q.Eq("Granularity", statboard.Monthly), q.Eq("Granularity", statboard.Granularity("")))
+	}
+	return q.Eq("Granularity", granularity)
+}
+
+// eventsBucket is the bucket holding raw events, separate from the metric rollups
+const eventsBucket = "events"
+
+// WriteEvents inserts or updates raw events in a single transaction
+func (s *stormStore) WriteEvents(events []statboard.Event) error {
+	tx, err := s.db.From(eventsBucket).Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range events {
+		if err := tx.Save(&events[i]); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to save event %q", events[i].ID))
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteEvents deletes the raw events with the given IDs in a single transaction
+func (s *stormStore) DeleteEvents(ids []string) (int, error) {
+	tx, err := s.db.From(eventsBucket).Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	for _, id := range ids {
+		err := tx.DeleteStruct(&statboard.Event{ID: id})
+		if err == storm.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete event %q", id))
+		}
+		n++
+	}
+	return n, tx.Commit()
+}
+
+// GetEvents returns the raw events of a source from (inclusive) to (exclusive) ordered by time
+func (s *stormStore) GetEvents(source string, from time.Time, to time.Time) ([]statboard.Event, error) {
+	var events []statboard.Event
+	query := s.db.From(eventsBucket).Select(q.Eq("Source", source), q.Gte("Timestamp", from), q.Lt("Timestamp", to))
+	err := query.OrderBy("Timestamp").Find(&events)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return events, nil
+		}
+		return nil, err
+	}
+	return events, nil
+}
+
+// WriteRun inserts or updates a collection run in database
+func (s *stormStore) WriteRun(r statboard.Run) error {
+	r.ID = runID(r)
+	return s.db.Save(&r)
+}
+
+// runID returns the ID a collection run is stored under
+func runID(r statboard.Run) string {
+	return fmt.Sprintf("%s-%d", r.Metric, r.Start.UnixNano())
+}
+
+// GetRuns returns all collection runs for a metric ordered by start time
+func (s *stormStore) GetRuns(metric string) ([]statboard.Run, error) {
+	var runs []statboard.Run
+	err := s.db.Select(q.Eq("Metric", metric)).OrderBy("Start").Find(&runs)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return runs, nil
+		}
+		return nil, err
+	}
+	return runs, nil
+}
+
+// PruneRuns deletes all but the newest keep collection runs of a metric in a
+// single transaction, always keeping the latest successful run
+func (s *stormStore) PruneRuns(metric string, keep int) (int, error) {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var runs []statboard.Run
+	if err := tx.Select(q.Eq("Metric", metric)).OrderBy("Start").Find(&runs); err != nil {
+		if err == storm.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	lastSuccess := -1
+	for i := range runs {
+		if runs[i].Succeeded() {
+			lastSuccess = i
+		}
+	}
+
+	var n int
+	for i := 0; i < len(runs)-keep; i++ {
+		if i == lastSuccess {
+			continue
+		}
+		if err := tx.DeleteStruct(&runs[i]); err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("failed to delete run %q", runs[i].ID))
+		}
+		n++
+	}
+	return n, tx.Commit()
+}
+
+// WriteCheckpoint inserts or updates the collection checkpoint of a metric
+func (s *stormStore) WriteCheckpoint(c statboard.Checkpoint) error {
+	return s.db.Save(&c)
+}
+
+// GetCheckpoint returns the collection checkpoint of a metric. A metric that
+// has never been collected has an empty checkpoint.
+func (s *stormStore) GetCheckpoint(metric string) (statboard.Checkpoint, error) {
+	var c statboard.Checkpoint
+	err := s.db.One("Metric", metric, &c)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return statboard.Checkpoint{Metric: metric}, nil
+		}
+		return c, err
+	}
+	return c, nil
 }
 
 // Close closes the database connection
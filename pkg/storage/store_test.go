@@ -33,7 +33,7 @@ func TestGetMetric_NoEntries(t *testing.T) {
 	defer os.Remove("test.db")
 	defer b.Close()
 
-	metrics, err := b.GetMetric("testMetric", time.Now())
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, time.Now())
 	assert.NoError(t, err)
 
 	var expected []statboard.Metric
@@ -56,14 +56,15 @@ func TestGetMetric_Entries(t *testing.T) {
 		Date:  testTime,
 		Value: 3.0,
 	}
-	m.ID = fmt.Sprintf("%s-%s", m.Date, m.Name)
 
 	err = b.WriteMetric(m)
 	assert.NoError(t, err)
 
-	metrics, err := b.GetMetric("testMetric", testTime.AddDate(0, 0, -1))
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, testTime.AddDate(0, 0, -1))
 	assert.NoError(t, err)
 
+	m.ID = "testMetric:month:2018-01"
+	m.Granularity = statboard.Monthly
 	expected := []statboard.Metric{m}
 
 	assert.Equal(t, expected, metrics)
@@ -89,10 +90,361 @@ func TestGetMetric_NoValidEntries(t *testing.T) {
 	err = b.WriteMetric(m)
 	assert.NoError(t, err)
 
-	metrics, err := b.GetMetric("testMetric", time.Now())
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, time.Now())
 	assert.NoError(t, err)
 
 	var expected []statboard.Metric
 
 	assert.Equal(t, expected, metrics)
 }
+
+func TestGetRuns(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime, err := time.Parse("2006-01-02", "2018-01-01")
+	assert.NoError(t, err)
+
+	runs := []statboard.Run{
+		{Metric: "testMetric", Start: testTime.Add(time.Hour), End: testTime.Add(2 * time.Hour), Error: "failed"},
+		{Metric: "testMetric", Start: testTime, End: testTime.Add(time.Minute), Records: 3},
+		{Metric: "otherMetric", Start: testTime, End: testTime.Add(time.Minute), Records: 1},
+	}
+	for _, r := range runs {
+		err = b.WriteRun(r)
+		assert.NoError(t, err)
+	}
+
+	actual, err := b.GetRuns("testMetric")
+	assert.NoError(t, err)
+
+	assert.Len(t, actual, 2)
+	assert.True(t, actual[0].Start.Equal(testTime))
+	assert.True(t, actual[0].Succeeded())
+	assert.False(t, actual[1].Succeeded())
+}
+
+func TestPruneRuns(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := []statboard.Run{
+		{Metric: "testMetric", Start: testTime, End: testTime.Add(time.Minute)},
+		{Metric: "testMetric", Start: testTime.Add(time.Hour), End: testTime.Add(time.Hour + time.Minute)},
+		{Metric: "testMetric", Start: testTime.Add(2 * time.Hour), End: testTime.Add(2*time.Hour + time.Minute), Error: "failed"},
+		{Metric: "testMetric", Start: testTime.Add(3 * time.Hour), End: testTime.Add(3*time.Hour + time.Minute), Error: "failed"},
+		{Metric: "otherMetric", Start: testTime, End: testTime.Add(time.Minute)},
+	}
+	for _, r := range runs {
+		assert.NoError(t, b.WriteRun(r))
+	}
+
+	// the two newest runs failed, so the latest success is kept as well
+	pruned, err := b.PruneRuns("testMetric", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	actual, err := b.GetRuns("testMetric")
+	assert.NoError(t, err)
+	assert.Len(t, actual, 3)
+	assert.True(t, actual[0].Start.Equal(testTime.Add(time.Hour)))
+	assert.True(t, actual[0].Succeeded())
+
+	other, err := b.GetRuns("otherMetric")
+	assert.NoError(t, err)
+	assert.Len(t, other, 1)
+}
+
+func TestGetRuns_NoEntries(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	runs, err := b.GetRuns("testMetric")
+	assert.NoError(t, err)
+
+	var expected []statboard.Run
+
+	assert.Equal(t, expected, runs)
+}
+
+func TestGetMetric_Granularity(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime, err := time.Parse("2006-01-02", "2018-01-01")
+	assert.NoError(t, err)
+
+	monthly := statboard.Metric{Name: "testMetric", Date: testTime, Value: 31.0}
+	daily := statboard.Metric{Name: "testMetric", Date: testTime, Value: 1.0, Granularity: statboard.Daily}
+
+	err = b.WriteMetric(monthly)
+	assert.NoError(t, err)
+	err = b.WriteMetric(daily)
+	assert.NoError(t, err)
+
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, testTime.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 31.0, metrics[0].Value)
+
+	metrics, err = b.GetMetric("testMetric", statboard.Daily, testTime.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 1.0, metrics[0].Value)
+}
+
+func TestGetLatestMetric(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	_, ok, err := b.GetLatestMetric("testMetric", statboard.Monthly)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	metrics := []statboard.Metric{
+		{Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 3.0},
+		{Name: "testMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 1.0},
+		{Name: "testMetric", Date: time.Date(2018, 4, 2, 0, 0, 0, 0, time.UTC), Value: 1.0, Granularity: statboard.Daily},
+		{Name: "otherMetric", Date: time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC), Value: 5.0},
+	}
+	assert.NoError(t, b.WriteMetrics(metrics))
+	// a legacy record of the latest month is ignored in favour of the canonical one
+	legacy := statboard.Metric{ID: "legacy-march", Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 9.0}
+	assert.NoError(t, b.(*stormStore).db.Save(&legacy))
+
+	latest, ok, err := b.GetLatestMetric("testMetric", statboard.Monthly)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), latest.Date)
+	assert.Equal(t, 3.0, latest.Value)
+
+	latest, ok, err = b.GetLatestMetric("testMetric", statboard.Daily)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, latest.Date.Day())
+}
+
+func TestGetEvents(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime, err := time.Parse("2006-01-02", "2018-01-01")
+	assert.NoError(t, err)
+
+	events := []statboard.Event{
+		{ID: "b", Source: "testSource", Kind: "testKind", Timestamp: testTime.AddDate(0, 0, 1), Value: 2.0},
+		{ID: "a", Source: "testSource", Kind: "testKind", Timestamp: testTime, Value: 1.0, Attributes: map[string]string{"key": "value"}},
+		{ID: "c", Source: "testSource", Kind: "testKind", Timestamp: testTime.AddDate(0, 1, 0), Value: 3.0},
+		{ID: "d", Source: "otherSource", Kind: "testKind", Timestamp: testTime, Value: 4.0},
+	}
+	err = b.WriteEvents(events)
+	assert.NoError(t, err)
+
+	actual, err := b.GetEvents("testSource", testTime, testTime.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+
+	assert.Len(t, actual, 2)
+	assert.Equal(t, "a", actual[0].ID)
+	assert.Equal(t, map[string]string{"key": "value"}, actual[0].Attributes)
+	assert.Equal(t, "b", actual[1].ID)
+
+	// metrics are stored separately from events
+	metrics, err := b.GetMetric("testSource", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, metrics)
+}
+
+func TestDeleteEvents(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, b.WriteEvents([]statboard.Event{
+		{ID: "a", Source: "testSource", Timestamp: testTime, Value: 1.0},
+		{ID: "b", Source: "testSource", Timestamp: testTime, Value: 2.0},
+	}))
+
+	n, err := b.DeleteEvents([]string{"a", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	actual, err := b.GetEvents("testSource", testTime, testTime.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, "b", actual[0].ID)
+}
+
+func TestGetCheckpoint(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	cp, err := b.GetCheckpoint("testMetric")
+	assert.NoError(t, err)
+	assert.True(t, cp.HighWaterMark.IsZero())
+
+	mark := time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+	err = b.WriteCheckpoint(statboard.Checkpoint{Metric: "testMetric", HighWaterMark: mark})
+	assert.NoError(t, err)
+
+	cp, err = b.GetCheckpoint("testMetric")
+	assert.NoError(t, err)
+	assert.True(t, mark.Equal(cp.HighWaterMark))
+}
+
+func TestGetMetricRange(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.WriteMetrics([]statboard.Metric{
+		{Name: "testMetric", Date: testTime.AddDate(0, 2, 0), Value: 3.0},
+		{Name: "testMetric", Date: testTime, Value: 1.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 2.0},
+		{Name: "otherMetric", Date: testTime, Value: 4.0},
+	})
+	assert.NoError(t, err)
+
+	metrics, err := b.GetMetricRange("testMetric", statboard.Monthly, testTime, testTime.AddDate(0, 2, 0))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, 1.0, metrics[0].Value)
+	assert.Equal(t, 2.0, metrics[1].Value)
+
+	names, err := b.ListMetricNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"otherMetric", "testMetric"}, names)
+}
+
+func TestDeleteMetric(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.WriteMetrics([]statboard.Metric{
+		{Name: "testMetric", Date: testTime, Value: 1.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 2.0},
+		{Name: "testMetric", Date: testTime.AddDate(0, 1, 0), Value: 2.0, Granularity: statboard.Daily},
+		{Name: "otherMetric", Date: testTime.AddDate(0, 1, 0), Value: 4.0},
+	})
+	assert.NoError(t, err)
+
+	n, err := b.DeleteMetric("testMetric", testTime.AddDate(0, 1, 0), time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+
+	n, err = b.DeleteMetric("missingMetric", time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestDeleteMetricRange(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	testTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = b.WriteMetrics([]statboard.Metric{
+		{Name: "testMetric", Date: testTime, Value: 1.0, Granularity: statboard.Daily},
+		{Name: "testMetric", Date: testTime.AddDate(0, 0, 1), Value: 2.0, Granularity: statboard.Daily},
+		{Name: "testMetric", Date: testTime, Value: 3.0},
+	})
+	assert.NoError(t, err)
+
+	n, err := b.DeleteMetricRange("testMetric", statboard.Daily, time.Time{}, testTime.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	daily, err := b.GetMetric("testMetric", statboard.Daily, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, daily, 1)
+	assert.Equal(t, 2.0, daily[0].Value)
+
+	monthly, err := b.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, monthly, 1)
+}
+
+func TestDedupeMetrics(t *testing.T) {
+	b, err := NewStormStore("test.db")
+	assert.NoError(t, err)
+
+	defer os.Remove("test.db")
+	defer b.Close()
+
+	// records of the same months written under the old time.Time String IDs
+	// from two time zones
+	eastern := time.FixedZone("EST", -5*60*60)
+	legacy := []statboard.Metric{
+		{Name: "testMetric", Date: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), Value: 5.0},
+		{Name: "testMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 3.0},
+		{Name: "testMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, eastern), Value: 4.0},
+		{Name: "otherMetric", Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Value: 1.0},
+		{Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 9.0},
+	}
+	db := b.(*stormStore).db
+	for _, m := range legacy {
+		m.ID = fmt.Sprintf("%s-%s", m.Date, m.Name)
+		assert.NoError(t, db.Save(&m))
+	}
+	// March was corrected to a lower value since
+	assert.NoError(t, b.WriteMetric(statboard.Metric{Name: "testMetric", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Value: 2.0}))
+
+	// reads return one record per period before the migration runs
+	metrics, err := b.GetMetric("testMetric", statboard.Monthly, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 3)
+	assert.Equal(t, 4.0, metrics[0].Value)
+	assert.Equal(t, 5.0, metrics[1].Value)
+	assert.Equal(t, 2.0, metrics[2].Value)
+
+	removed, err := DedupeMetrics(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	var stored []statboard.Metric
+	assert.NoError(t, db.All(&stored))
+	assert.Len(t, stored, 4)
+	for _, m := range stored {
+		assert.Equal(t, metricID(m), m.ID)
+		if m.Date.Month() == time.March {
+			assert.Equal(t, 2.0, m.Value)
+		}
+	}
+}